@@ -8,6 +8,7 @@ import (
 
 	"github.com/LK4D4/grfuse/grpcfs"
 	"github.com/LK4D4/grfuse/pb"
+	"github.com/hanwen/go-fuse/fuse"
 	"github.com/hanwen/go-fuse/fuse/nodefs"
 	"github.com/hanwen/go-fuse/fuse/pathfs"
 	"google.golang.org/grpc"
@@ -28,7 +29,12 @@ func main() {
 	cli := pb.NewPathFSClient(conn)
 	fs := grpcfs.New(cli)
 	nfs := pathfs.NewPathNodeFs(fs, nil)
-	server, _, err := nodefs.MountRoot(root, nfs.Root(), nil)
+	mountOpts := &fuse.MountOptions{}
+	if fs.ReadOnly() {
+		mountOpts.Options = append(mountOpts.Options, "ro")
+	}
+	fsConn := nodefs.NewFileSystemConnector(nfs.Root(), nil)
+	server, err := fuse.NewServer(fsConn.RawFS(), root, mountOpts)
 	if err != nil {
 		log.Fatal(err)
 	}
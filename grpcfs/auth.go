@@ -0,0 +1,23 @@
+package grpcfs
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// BearerToken returns the dial option authenticating every call with
+// token, as server.TokenAuth expects. Tokens are only sent over
+// connections secured with TLS.
+func BearerToken(token string) grpc.DialOption {
+	return grpc.WithPerRPCCredentials(bearerToken(token))
+}
+
+type bearerToken string
+
+func (t bearerToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t bearerToken) RequireTransportSecurity() bool {
+	return true
+}
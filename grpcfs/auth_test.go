@@ -0,0 +1,92 @@
+package grpcfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LK4D4/grfuse/pb"
+	"github.com/LK4D4/grfuse/server"
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// principalFs records the principal of GetAttr calls.
+type principalFs struct {
+	HelloFs
+	name chan string
+}
+
+func (fs *principalFs) GetAttr(name string, context *fuse.Context) (*fuse.Attr, fuse.Status) {
+	if p, ok := server.PrincipalOf(context); ok {
+		fs.name <- p.Name
+	} else {
+		fs.name <- ""
+	}
+	return fs.HelloFs.GetAttr(name, context)
+}
+
+func TestAuth(t *testing.T) {
+	dir, cleanup := tlsFiles(t)
+	defer cleanup()
+	fs := &principalFs{
+		HelloFs: HelloFs{FileSystem: pathfs.NewDefaultFileSystem()},
+		name:    make(chan string, 1),
+	}
+	bob := &server.Principal{
+		Name: "bob",
+		UIDs: []server.IDRange{{First: 1000, Last: 1999}},
+		GIDs: []server.IDRange{{First: 100, Last: 100}},
+	}
+	auth := &server.Auth{Authenticator: server.TokenAuth{"secret": bob}}
+	addr, stop := serveTLS(t, dir, server.New(fs),
+		grpc.UnaryInterceptor(auth.UnaryServerInterceptor()),
+		grpc.StreamInterceptor(auth.StreamServerInterceptor()),
+	)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	getAttr := func(client pb.PathFSClient, uid, gid uint32) error {
+		_, err := client.GetAttr(ctx, &pb.GetAttrRequest{
+			Name:    "file.txt",
+			Context: &pb.Context{Owner: &pb.Owner{Uid: uid, Gid: gid}},
+		})
+		return err
+	}
+
+	client, closeConn := dialTLS(t, dir, addr, false, BearerToken("secret"))
+	defer closeConn()
+	if err := getAttr(client, 1000, 100); err != nil {
+		t.Fatal(err)
+	}
+	if name := <-fs.name; name != "bob" {
+		t.Fatalf("file system sees principal %q, want bob", name)
+	}
+	if err := getAttr(client, 0, 0); grpc.Code(err) != codes.PermissionDenied {
+		t.Fatalf("acting as root: got %v, want PermissionDenied", err)
+	}
+	if _, err := client.Chown(ctx, &pb.ChownRequest{Name: "file.txt", UID: 0, GID: 100}); grpc.Code(err) != codes.PermissionDenied {
+		t.Fatalf("chown to root: got %v, want PermissionDenied", err)
+	}
+
+	stranger, closeStranger := dialTLS(t, dir, addr, false, BearerToken("guess"))
+	defer closeStranger()
+	if err := getAttr(stranger, 1000, 100); grpc.Code(err) != codes.Unauthenticated {
+		t.Fatalf("unknown token: got %v, want Unauthenticated", err)
+	}
+
+	rewrite := &server.Auth{Authenticator: auth.Authenticator, Rewrite: true}
+	raddr, rstop := serveTLS(t, dir, server.New(fs),
+		grpc.UnaryInterceptor(rewrite.UnaryServerInterceptor()),
+	)
+	defer rstop()
+	rclient, closeRclient := dialTLS(t, dir, raddr, false, BearerToken("secret"))
+	defer closeRclient()
+	if err := getAttr(rclient, 0, 0); err != nil {
+		t.Fatalf("rewriting owner: %v", err)
+	}
+	<-fs.name
+}
@@ -0,0 +1,132 @@
+package grpcfs
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hanwen/go-fuse/fuse"
+)
+
+// minSweep is the number of entries an attrCache holds before it starts
+// dropping expired ones.
+const minSweep = 1024
+
+// attrCache caches file attributes by path for a fixed amount of time.
+// Paths which don't exist are remembered for negTTL, so repeated lookups of
+// them don't go to the server either. A zero ttl or negTTL disables the
+// respective kind of caching.
+type attrCache struct {
+	ttl    time.Duration
+	negTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]attrEntry
+	sweepAt int
+}
+
+type attrEntry struct {
+	attr fuse.Attr
+	// negative entries record that the path doesn't exist.
+	negative bool
+	expires  time.Time
+}
+
+func newAttrCache(ttl, negTTL time.Duration) *attrCache {
+	return &attrCache{
+		ttl:     ttl,
+		negTTL:  negTTL,
+		entries: make(map[string]attrEntry),
+		sweepAt: minSweep,
+	}
+}
+
+func (c *attrCache) disabled() bool {
+	return c.ttl == 0 && c.negTTL == 0
+}
+
+// get returns a copy of the cached attributes of name, or ENOENT if name
+// is known not to exist. The last result reports whether name was cached.
+func (c *attrCache) get(name string) (*fuse.Attr, fuse.Status, bool) {
+	if c.disabled() {
+		return nil, fuse.OK, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[name]
+	if !ok {
+		return nil, fuse.OK, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, name)
+		return nil, fuse.OK, false
+	}
+	if e.negative {
+		return nil, fuse.ENOENT, true
+	}
+	attr := e.attr
+	return &attr, fuse.OK, true
+}
+
+func (c *attrCache) set(name string, attr *fuse.Attr) {
+	if c.ttl == 0 {
+		return
+	}
+	c.add(name, attrEntry{attr: *attr}, c.ttl)
+}
+
+// setNegative records that name doesn't exist.
+func (c *attrCache) setNegative(name string) {
+	if c.negTTL == 0 {
+		return
+	}
+	c.add(name, attrEntry{negative: true}, c.negTTL)
+}
+
+func (c *attrCache) add(name string, e attrEntry, ttl time.Duration) {
+	now := time.Now()
+	e.expires = now.Add(ttl)
+	c.mu.Lock()
+	c.entries[name] = e
+	if len(c.entries) >= c.sweepAt {
+		c.sweep(now)
+	}
+	c.mu.Unlock()
+}
+
+// sweep drops expired entries. c.mu must be held.
+func (c *attrCache) sweep(now time.Time) {
+	for name, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, name)
+		}
+	}
+	c.sweepAt = 2 * len(c.entries)
+	if c.sweepAt < minSweep {
+		c.sweepAt = minSweep
+	}
+}
+
+func (c *attrCache) invalidate(name string) {
+	if c.disabled() {
+		return
+	}
+	c.mu.Lock()
+	delete(c.entries, name)
+	c.mu.Unlock()
+}
+
+// invalidateTree drops name and everything below it.
+func (c *attrCache) invalidateTree(name string) {
+	if c.disabled() {
+		return
+	}
+	prefix := name + "/"
+	c.mu.Lock()
+	for n := range c.entries {
+		if n == name || name == "" || strings.HasPrefix(n, prefix) {
+			delete(c.entries, n)
+		}
+	}
+	c.mu.Unlock()
+}
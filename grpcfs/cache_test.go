@@ -0,0 +1,123 @@
+package grpcfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LK4D4/grfuse/server"
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+)
+
+func TestAttrCache(t *testing.T) {
+	c := newAttrCache(time.Minute, 0)
+	c.set("dir", &fuse.Attr{Mode: fuse.S_IFDIR | 0755})
+	c.set("dir/file", &fuse.Attr{Mode: fuse.S_IFREG | 0644, Size: 42})
+	c.set("dirfile", &fuse.Attr{Mode: fuse.S_IFREG | 0644})
+
+	attr, _, ok := c.get("dir/file")
+	if !ok {
+		t.Fatal("expected dir/file to be cached")
+	}
+	if attr.Size != 42 {
+		t.Fatalf("expected size 42, got %d", attr.Size)
+	}
+	attr.Size = 0
+	if attr, _, _ := c.get("dir/file"); attr.Size != 42 {
+		t.Fatal("modifying returned attributes changed the cache")
+	}
+
+	c.invalidateTree("dir")
+	for _, name := range []string{"dir", "dir/file"} {
+		if _, _, ok := c.get(name); ok {
+			t.Fatalf("expected %s to be invalidated", name)
+		}
+	}
+	if _, _, ok := c.get("dirfile"); !ok {
+		t.Fatal("dirfile shouldn't be invalidated together with dir")
+	}
+}
+
+func TestAttrCacheExpiry(t *testing.T) {
+	c := newAttrCache(time.Millisecond, time.Millisecond)
+	c.set("file", &fuse.Attr{})
+	c.setNegative("missing")
+	time.Sleep(10 * time.Millisecond)
+	for _, name := range []string{"file", "missing"} {
+		if _, _, ok := c.get(name); ok {
+			t.Fatalf("expected %s to expire", name)
+		}
+	}
+}
+
+func TestAttrCacheDisabled(t *testing.T) {
+	c := newAttrCache(0, 0)
+	c.set("file", &fuse.Attr{})
+	c.setNegative("missing")
+	for _, name := range []string{"file", "missing"} {
+		if _, _, ok := c.get(name); ok {
+			t.Fatal("nothing should be cached with zero timeouts")
+		}
+	}
+}
+
+func TestAttrCacheNegative(t *testing.T) {
+	c := newAttrCache(0, time.Minute)
+	c.set("file", &fuse.Attr{})
+	if _, _, ok := c.get("file"); ok {
+		t.Fatal("attributes shouldn't be cached with zero timeout")
+	}
+	c.setNegative("missing")
+	if _, code, ok := c.get("missing"); !ok || code != fuse.ENOENT {
+		t.Fatalf("expected cached ENOENT, got %v (cached: %v)", code, ok)
+	}
+	c.invalidate("missing")
+	if _, _, ok := c.get("missing"); ok {
+		t.Fatal("expected missing to be invalidated")
+	}
+}
+
+// countingFs counts GetAttr calls.
+type countingFs struct {
+	HelloFs
+	getAttrs int
+}
+
+func (fs *countingFs) GetAttr(name string, context *fuse.Context) (*fuse.Attr, fuse.Status) {
+	fs.getAttrs++
+	return fs.HelloFs.GetAttr(name, context)
+}
+
+func (fs *countingFs) SetXAttr(name string, attr string, data []byte, flags int, context *fuse.Context) fuse.Status {
+	return fuse.OK
+}
+
+func (fs *countingFs) RemoveXAttr(name string, attr string, context *fuse.Context) fuse.Status {
+	return fuse.OK
+}
+
+func TestAttrCacheXAttr(t *testing.T) {
+	backend := &countingFs{HelloFs: HelloFs{FileSystem: pathfs.NewDefaultFileSystem()}}
+	plain, stop := dialFs(t, server.New(backend))
+	defer stop()
+	fs := NewWithOptions(plain.client, Options{AttrTimeout: time.Minute})
+
+	for _, change := range []func() fuse.Status{
+		func() fuse.Status { return fs.SetXAttr("file.txt", "user.a", []byte("b"), 0, &fuse.Context{}) },
+		func() fuse.Status { return fs.RemoveXAttr("file.txt", "user.a", &fuse.Context{}) },
+	} {
+		if _, code := fs.GetAttr("file.txt", &fuse.Context{}); code != fuse.OK {
+			t.Fatal(code)
+		}
+		calls := backend.getAttrs
+		if code := change(); code != fuse.OK {
+			t.Fatal(code)
+		}
+		if _, code := fs.GetAttr("file.txt", &fuse.Context{}); code != fuse.OK {
+			t.Fatal(code)
+		}
+		if backend.getAttrs == calls {
+			t.Fatal("attributes still cached after changing extended attributes")
+		}
+	}
+}
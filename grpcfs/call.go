@@ -0,0 +1,288 @@
+package grpcfs
+
+import (
+	"log"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/LK4D4/grfuse/pb"
+	"github.com/hanwen/go-fuse/fuse"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// opClass selects the deadline of a call.
+type opClass int
+
+const (
+	// metadataOp calls work on names and attributes.
+	metadataOp opClass = iota
+	// dataOp calls move file contents.
+	dataOp
+)
+
+func (fs *GrpcFs) timeout(class opClass) time.Duration {
+	if class == dataOp {
+		return fs.dataTimeout
+	}
+	return fs.metadataTimeout
+}
+
+// call runs f, which makes the RPC op on name with the options it is
+// passed and returns the status of the response if there is one, with a
+// context carrying the deadline for class. Failed calls are retried
+// according to the mount mode; caller is the process the call is made
+// for, if known.
+func (fs *GrpcFs) call(class opClass, caller *fuse.Context, op, name string, f func(context.Context, ...grpc.CallOption) (*pb.Status, error)) error {
+	id := fs.requestID()
+	return fs.retry(caller, idempotentOps[op], func() (bool, error) {
+		rctx, cancel := fs.opContext(class)
+		defer cancel()
+		interrupted := fs.cancelOnSignal(caller, cancel)
+		start := time.Now()
+		var p peer.Peer
+		st, err := f(fs.outgoing(rctx, id), grpc.Peer(&p))
+		if interrupted() {
+			err = errInterrupted
+		}
+		code := fuse.OK
+		if st != nil {
+			code = st.Code
+		}
+		fs.debugCall(id, op, name, start, code, err)
+		return p.Addr != nil, err
+	})
+}
+
+// outgoing attaches the client id and the request id, if any, to the
+// metadata sent with a call.
+func (fs *GrpcFs) outgoing(ctx context.Context, id string) context.Context {
+	md := metadata.Pairs(clientIDKey, fs.clientID)
+	if id != "" {
+		md[requestIDKey] = []string{id}
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// idempotentOps are the calls which only read, and so can be repeated
+// even if the server might have run them already.
+var idempotentOps = map[string]bool{
+	"GetAttr":       true,
+	"Access":        true,
+	"OpenDir":       true,
+	"OpenDirStream": true,
+	"GetXAttr":      true,
+	"ListXAttr":     true,
+	"Readlink":      true,
+	"StatFs":        true,
+	"String":        true,
+	"Read":          true,
+	"Hello":         true,
+}
+
+// retryable reports whether err means that the server couldn't be reached,
+// or, for idempotent calls, didn't answer in time. Other calls which may
+// have reached the server, and so may have been run by it, aren't
+// repeated: sent reports whether a stream to the server was opened for
+// the call, without which it can't have been.
+func retryable(err error, idempotent, sent bool) bool {
+	switch grpc.Code(err) {
+	case codes.Unavailable:
+		return idempotent || !sent
+	case codes.DeadlineExceeded:
+		return idempotent
+	}
+	return false
+}
+
+// retry runs attempt until it succeeds, fails for a reason other than the
+// connection to the server, or the mount mode gives up on it. attempt
+// reports whether the call might have reached the server; those which
+// did fail with EIO if they can't be repeated, even in hard mounts.
+func (fs *GrpcFs) retry(caller *fuse.Context, idempotent bool, attempt func() (bool, error)) error {
+	start := time.Now()
+	backoff := minRetryBackoff
+	for retries := 0; ; retries++ {
+		sent, err := attempt()
+		if fs.mount == NoRetry || !retryable(err, idempotent, sent) {
+			if err == nil && retries > 0 {
+				log.Printf("Server is responding again after %d retries", retries)
+			}
+			if fs.mount != NoRetry && grpc.Code(err) == codes.Unavailable {
+				return softError{err}
+			}
+			return err
+		}
+		if fs.mount == SoftMount {
+			if retries >= fs.retries || (fs.retryTimeout > 0 && time.Since(start)+backoff > fs.retryTimeout) {
+				return softError{err}
+			}
+		}
+		if retries == 0 {
+			log.Printf("Server is not responding, still trying: %v", err)
+		}
+		if !fs.sleep(backoff, caller) {
+			return errInterrupted
+		}
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+// sleep waits for d and reports whether it did so without being
+// interrupted by a signal for caller.
+func (fs *GrpcFs) sleep(d time.Duration, caller *fuse.Context) bool {
+	if !fs.intr || caller == nil {
+		time.Sleep(d)
+		return true
+	}
+	for end := time.Now().Add(d); time.Now().Before(end); {
+		if signalPending(caller.Pid) {
+			return false
+		}
+		step := end.Sub(time.Now())
+		if step > intrPoll {
+			step = intrPoll
+		}
+		time.Sleep(step)
+	}
+	return !signalPending(caller.Pid)
+}
+
+// cancelOnSignal cancels a call made for caller once the process has a
+// signal pending, which is when the kernel sends a FUSE INTERRUPT. The
+// returned function stops watching and reports whether it cancelled.
+func (fs *GrpcFs) cancelOnSignal(caller *fuse.Context, cancel context.CancelFunc) func() bool {
+	if !fs.intr || caller == nil {
+		return func() bool { return false }
+	}
+	var interrupted int32
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(intrPoll)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				if signalPending(caller.Pid) {
+					atomic.StoreInt32(&interrupted, 1)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	return func() bool {
+		close(done)
+		return atomic.LoadInt32(&interrupted) != 0
+	}
+}
+
+func (fs *GrpcFs) opContext(class opClass) (context.Context, context.CancelFunc) {
+	if d := fs.timeout(class); d > 0 {
+		return context.WithTimeout(context.Background(), d)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// watchdog cancels a stream unless the returned function is called within
+// the deadline for class. It bounds the wait for a single message, as
+// streams may stay open much longer than any single call. The returned
+// function reports whether the deadline was met.
+func (fs *GrpcFs) watchdog(class opClass, cancel context.CancelFunc) func() bool {
+	d := fs.timeout(class)
+	if d == 0 {
+		return func() bool { return true }
+	}
+	t := time.AfterFunc(d, cancel)
+	return t.Stop
+}
+
+const (
+	// minRetryBackoff and maxRetryBackoff bound the delay between
+	// retries of a call.
+	minRetryBackoff = 100 * time.Millisecond
+	maxRetryBackoff = 10 * time.Second
+	// intrPoll is how often an Intr mount checks for signals while a
+	// call runs or waits to be retried.
+	intrPoll = 100 * time.Millisecond
+)
+
+// softError is returned by soft mounts giving up on a call.
+type softError struct {
+	err error
+}
+
+func (e softError) Error() string {
+	return "giving up: " + e.err.Error()
+}
+
+// errInterrupted is returned if the process a call was made for got a
+// signal while the call was running or being retried.
+var errInterrupted = grpc.Errorf(codes.Canceled, "interrupted by signal")
+
+// errStalled is returned for streams stopped by a watchdog.
+var errStalled = grpc.Errorf(codes.DeadlineExceeded, "no progress on stream within deadline")
+
+// codeErrno maps the codes of failed calls to the errnos reported to the
+// kernel. Internal and Unknown mean that something went wrong on the
+// server, e.g. a call panicked, and are reported as EIO, as are codes
+// which are missing.
+var codeErrno = map[codes.Code]syscall.Errno{
+	codes.Internal:          syscall.EIO,
+	codes.Unknown:           syscall.EIO,
+	codes.Canceled:          syscall.EINTR,
+	codes.InvalidArgument:   syscall.EINVAL,
+	codes.DeadlineExceeded:  syscall.ETIMEDOUT,
+	codes.NotFound:          syscall.ENOENT,
+	codes.AlreadyExists:     syscall.EEXIST,
+	codes.PermissionDenied:  syscall.EACCES,
+	codes.Unauthenticated:   syscall.EACCES,
+	codes.ResourceExhausted: syscall.ENOSPC,
+	codes.OutOfRange:        syscall.ERANGE,
+	codes.Unimplemented:     syscall.ENOSYS,
+	codes.Unavailable:       syscall.ENOTCONN,
+}
+
+// toStatus converts an error returned by a call to the status reported to
+// the kernel.
+func toStatus(err error) fuse.Status {
+	if _, ok := err.(softError); ok {
+		log.Printf("Call failed: %v", err)
+		return fuse.EIO
+	}
+	code := grpc.Code(err)
+	if code != codes.Canceled {
+		log.Printf("Call failed with %v: %s", code, grpc.ErrorDesc(err))
+	}
+	if errno, ok := codeErrno[code]; ok {
+		return fuse.Status(errno)
+	}
+	return fuse.EIO
+}
+
+// statusCode returns the code of a status sent by the server, logging the
+// details of unexpected failures.
+func statusCode(st *pb.Status) fuse.Status {
+	switch st.Code {
+	case fuse.OK, fuse.ENOENT, fuse.ENODATA, fuse.ENOSYS, fuse.EACCES, fuse.EPERM, fuse.ENOTDIR, fuse.ERANGE,
+		fuse.Status(syscall.EEXIST), fuse.Status(syscall.ENOTEMPTY), fuse.Status(syscall.EISDIR), fuse.Status(syscall.EROFS):
+		// Ordinary results of file system calls.
+	default:
+		if st.Path != "" && !strings.Contains(st.Message, st.Path) {
+			// Older servers don't name the path in the message.
+			log.Printf("Server error on %s: %s", st.Path, st.Message)
+		} else {
+			log.Printf("Server error: %s", st.Message)
+		}
+	}
+	return st.Code
+}
@@ -0,0 +1,64 @@
+package grpcfs
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/LK4D4/grfuse/pb"
+	"github.com/hanwen/go-fuse/fuse"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestToStatus(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		want fuse.Status
+	}{
+		{grpc.Errorf(codes.NotFound, "no file"), fuse.ENOENT},
+		{grpc.Errorf(codes.PermissionDenied, "policy"), fuse.EACCES},
+		{grpc.Errorf(codes.Unavailable, "no connection"), fuse.Status(syscall.ENOTCONN)},
+		{grpc.Errorf(codes.Internal, "panic in Read"), fuse.EIO},
+		{grpc.Errorf(codes.Unknown, "whatever"), fuse.EIO},
+		{grpc.Errorf(codes.DataLoss, "unmapped"), fuse.EIO},
+		{errors.New("not from grpc"), fuse.EIO},
+		{softError{grpc.Errorf(codes.Unavailable, "no connection")}, fuse.EIO},
+		{errInterrupted, fuse.Status(syscall.EINTR)},
+		{errStalled, fuse.Status(syscall.ETIMEDOUT)},
+	} {
+		if got := toStatus(c.err); got != c.want {
+			t.Errorf("toStatus(%v): got %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	for _, c := range []struct {
+		st     *pb.Status
+		logged string
+	}{
+		{&pb.Status{Code: fuse.OK}, ""},
+		{&pb.Status{Code: fuse.ENOENT, Path: "f", Message: `GetAttr "f": no such file or directory`}, ""},
+		{&pb.Status{Code: fuse.EIO, Path: "f", Message: `Read "f": input/output error`}, `Server error: Read "f": input/output error`},
+		// Older servers don't name the path in the message.
+		{&pb.Status{Code: fuse.EIO, Path: "f", Message: "input/output error"}, "Server error on f: input/output error"},
+		{&pb.Status{Code: fuse.EBADF, Message: "Read: unknown file handle 1"}, "Server error: Read: unknown file handle 1"},
+	} {
+		buf.Reset()
+		if got := statusCode(c.st); got != c.st.Code {
+			t.Errorf("statusCode(%v): got %v", c.st, got)
+		}
+		logged := strings.TrimSpace(buf.String())
+		if c.logged == "" && logged != "" || !strings.HasSuffix(logged, c.logged) {
+			t.Errorf("statusCode(%v) logged %q, want %q", c.st, logged, c.logged)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package grpcfs
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/LK4D4/grfuse/pb"
+	"github.com/hanwen/go-fuse/fuse"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// requestIDKey is the metadata key under which calls carry their request
+// id while debugging, so the server can log it. The server package uses
+// the same key.
+const requestIDKey = "grfuse-request-id"
+
+// SetDebug turns logging of every call on or off. With
+// Options.ForwardDebug, the server is told to do the same.
+func (fs *GrpcFs) SetDebug(debug bool) {
+	var v int32
+	if debug {
+		v = 1
+	}
+	atomic.StoreInt32(&fs.debug, v)
+	if !fs.forwardDebug {
+		return
+	}
+	req := &pb.SetDebugRequest{
+		Debug: debug,
+	}
+	err := fs.call(metadataOp, nil, "SetDebug", "", func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		_, err = fs.client.SetDebug(rctx, req, opts...)
+		return nil, err
+	})
+	if err != nil {
+		log.Printf("Error forwarding debug mode to server: %v", err)
+	}
+}
+
+func (fs *GrpcFs) debugging() bool {
+	return atomic.LoadInt32(&fs.debug) != 0
+}
+
+// clientIDKey is the metadata key under which calls carry the client id,
+// which the server uses to not send clients the changes they made
+// themselves. The server package uses the same key.
+const clientIDKey = "grfuse-client-id"
+
+// newClientID returns the id of a GrpcFs, which tells clients of the same
+// server apart. It's also the prefix of its request ids.
+func newClientID() string {
+	return fmt.Sprintf("%08x", rand.New(rand.NewSource(time.Now().UnixNano())).Uint32())
+}
+
+// requestID returns the id of a new call, or "" if not debugging.
+func (fs *GrpcFs) requestID() string {
+	if !fs.debugging() {
+		return ""
+	}
+	return fmt.Sprintf("%s-%d", fs.clientID, atomic.AddUint64(&fs.lastRequest, 1))
+}
+
+// debugCall logs a call which started at start and ended with code or
+// err, if debugging.
+func (fs *GrpcFs) debugCall(id, op, name string, start time.Time, code fuse.Status, err error) {
+	if id == "" {
+		return
+	}
+	d := time.Since(start)
+	if err != nil {
+		log.Printf("[%s] %s %q: %v in %v", id, op, name, err, d)
+		return
+	}
+	log.Printf("[%s] %s %q: %v in %v", id, op, name, code, d)
+}
+
+// streamContext returns the context for a new stream, logging that it
+// starts if debugging.
+func (fs *GrpcFs) streamContext(op, name string) (context.Context, context.CancelFunc) {
+	id := fs.requestID()
+	if id != "" {
+		log.Printf("[%s] %s %q: started", id, op, name)
+	}
+	return context.WithCancel(fs.outgoing(context.Background(), id))
+}
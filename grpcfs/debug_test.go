@@ -0,0 +1,38 @@
+package grpcfs
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/LK4D4/grfuse/server"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+)
+
+func TestDebug(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	fs, stop := dialFs(t, server.NewWithOptions(&HelloFs{FileSystem: pathfs.NewDefaultFileSystem()}, server.Options{
+		DebugPrincipals: []string{"*"},
+	}))
+	defer stop()
+	fs.forwardDebug = true
+	fs.SetDebug(true)
+	fs.GetAttr("file.txt", nil)
+	fs.SetDebug(false)
+	fs.GetAttr("file.txt", nil)
+
+	// The client and the server log with the same request id.
+	ids := regexp.MustCompile(`\[(\S+)\] GetAttr "file.txt"`).FindAllStringSubmatch(buf.String(), -1)
+	if len(ids) != 2 || ids[0][1] != ids[1][1] {
+		t.Fatalf("expected matching client and server logs, got:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "GetAttr \"file.txt\": OK in") {
+		t.Fatalf("status not logged:\n%s", buf.String())
+	}
+}
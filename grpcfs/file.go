@@ -0,0 +1,555 @@
+package grpcfs
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"syscall"
+
+	"github.com/LK4D4/grfuse/pb"
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/nodefs"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// grpcFile is a nodefs.File which proxies every call to a file opened on
+// the server. Operations which are not implemented return ENOSYS, so
+// pathfs falls back to the path based methods of GrpcFs.
+//
+// Once reads become sequential, they are served from a ReadStream which
+// the server fills ahead of the reader, instead of a round trip per read.
+// Likewise sequential writes are sent over a WriteStream without waiting
+// for the server; errors from those are reported by the next Read, Flush
+// or Fsync.
+//
+// If the server doesn't know the handle anymore, e.g. after it was
+// restarted, the file is opened again by name and the call is repeated.
+// Files which are gone by then fail with ESTALE. Anything tied to the old
+// handle on the server, like locks or the position of a file opened with
+// O_APPEND, is lost.
+type grpcFile struct {
+	nodefs.File
+	fs   *GrpcFs
+	name string
+	// flags and context are used to open the file again, on behalf of
+	// the same owner.
+	flags   uint32
+	context *pb.Context
+
+	mu     sync.Mutex
+	handle uint64
+	// nextOff is the offset just past the last read, used to detect
+	// sequential reads.
+	nextOff int64
+	stream  *readStream
+	// nextWriteOff is the offset just past the last write.
+	nextWriteOff int64
+	wstream      *writeStream
+}
+
+const (
+	// maxUnackedWrite is how much data is sent over a WriteStream
+	// before waiting for the server to acknowledge it.
+	maxUnackedWrite = 4 << 20
+	// writeChunkSize is the chunk size used when resending data.
+	writeChunkSize = 128 << 10
+	// writeStreamRetries is how many times writes are resumed after
+	// a WriteStream broke.
+	writeStreamRetries = 3
+)
+
+func newFile(fs *GrpcFs, name string, flags uint32, context *pb.Context, handle uint64) nodefs.File {
+	return &grpcFile{
+		File:    nodefs.NewDefaultFile(),
+		fs:      fs,
+		name:    name,
+		flags:   flags &^ (syscall.O_CREAT | syscall.O_EXCL | syscall.O_TRUNC),
+		context: context,
+		handle:  handle,
+	}
+}
+
+func (f *grpcFile) String() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return fmt.Sprintf("grpcFile(%s, %d)", f.name, f.handle)
+}
+
+// stale reports whether code means that the server lost the handle.
+func stale(code fuse.Status) bool {
+	return code == fuse.Status(syscall.ESTALE)
+}
+
+// reopen opens the file again after the server lost handle. Nothing is
+// done if that happened already. f.mu must be held.
+func (f *grpcFile) reopen(handle uint64) fuse.Status {
+	if f.handle != handle {
+		return fuse.OK
+	}
+	// The server most likely restarted, maybe with another version.
+	f.fs.resetSession()
+	req := &pb.OpenRequest{
+		Name:    f.name,
+		Flags:   f.flags,
+		Context: f.context,
+		Handle:  true,
+	}
+	var resp *pb.OpenResponse
+	err := f.fs.call(metadataOp, nil, "Open", req.Name, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = f.fs.client.Open(rctx, req, opts...)
+		return resp.GetStatus(), err
+	})
+	if err != nil {
+		return toStatus(err)
+	}
+	code := statusCode(resp.Status)
+	if code == fuse.ENOENT {
+		code = fuse.Status(syscall.ESTALE)
+	}
+	if code != fuse.OK {
+		log.Printf("Error reopening %s after the server lost handle %d: %v", f.name, handle, code)
+		return code
+	}
+	log.Printf("Reopened %s after the server lost handle %d", f.name, handle)
+	f.closeStream()
+	f.handle = resp.File.Handle
+	return fuse.OK
+}
+
+// callHandle runs call, which makes the RPC op on the handle it is passed
+// and returns the status of the response. If the server lost the
+// handle, the file is opened again and call is repeated once.
+func (f *grpcFile) callHandle(op string, call func(rctx context.Context, handle uint64, opts ...grpc.CallOption) (*pb.Status, error)) fuse.Status {
+	for attempt := 0; ; attempt++ {
+		f.mu.Lock()
+		handle := f.handle
+		f.mu.Unlock()
+		var st *pb.Status
+		err := f.fs.call(dataOp, nil, op, f.name, func(rctx context.Context, opts ...grpc.CallOption) (*pb.Status, error) {
+			var err error
+			st, err = call(rctx, handle, opts...)
+			return st, err
+		})
+		if err != nil {
+			return toStatus(err)
+		}
+		if !stale(st.Code) || attempt > 0 {
+			return statusCode(st)
+		}
+		f.mu.Lock()
+		code := f.reopen(handle)
+		f.mu.Unlock()
+		if code != fuse.OK {
+			return code
+		}
+	}
+}
+
+// readStream is a ReadStream call from which sequential reads are served.
+type readStream struct {
+	stream pb.PathFS_ReadStreamClient
+	cancel context.CancelFunc
+	// off is the file offset of the first byte in buf.
+	off int64
+	buf []byte
+	eof bool
+}
+
+func (f *grpcFile) openStream(off int64) error {
+	ctx, cancel := f.fs.streamContext("ReadStream", f.name)
+	req := &pb.ReadStreamRequest{
+		Handle: f.handle,
+		Offset: off,
+	}
+	stream, err := f.fs.client.ReadStream(ctx, req)
+	if err != nil {
+		cancel()
+		return err
+	}
+	f.stream = &readStream{
+		stream: stream,
+		cancel: cancel,
+		off:    off,
+	}
+	return nil
+}
+
+func (f *grpcFile) closeStream() {
+	if f.stream != nil {
+		f.stream.cancel()
+		f.stream = nil
+	}
+}
+
+// read fills dest from the stream and returns the number of bytes read,
+// which is less than len(dest) only at the end of file.
+func (rs *readStream) read(dest []byte) (int, fuse.Status) {
+	n := 0
+	for n < len(dest) {
+		if len(rs.buf) == 0 {
+			if rs.eof {
+				break
+			}
+			resp, err := rs.stream.Recv()
+			if err == io.EOF {
+				rs.eof = true
+				break
+			}
+			if err != nil {
+				return n, toStatus(err)
+			}
+			if code := statusCode(resp.Status); code != fuse.OK {
+				return n, code
+			}
+			rs.buf = resp.Data
+		}
+		c := copy(dest[n:], rs.buf)
+		rs.buf = rs.buf[c:]
+		rs.off += int64(c)
+		n += c
+	}
+	return n, fuse.OK
+}
+
+// writeStream is a WriteStream call to which sequential writes are sent.
+type writeStream struct {
+	stream pb.PathFS_WriteStreamClient
+	cancel context.CancelFunc
+	// acked is the offset up to which the server acknowledged writes.
+	// unacked holds the data sent after it, to resend it if the stream
+	// breaks. The server only acknowledges a stream once it ends, so
+	// acked is where the stream started and a broken stream is sent
+	// again as a whole. Writes end the stream every maxUnackedWrite
+	// bytes, which bounds what is sent again.
+	acked   int64
+	unacked []byte
+}
+
+func (ws *writeStream) end() int64 {
+	return ws.acked + int64(len(ws.unacked))
+}
+
+// start opens a new stream and sends all unacknowledged data over it.
+func (ws *writeStream) start(fs *GrpcFs, name string, handle uint64) (err error) {
+	ctx, cancel := fs.streamContext("WriteStream", name)
+	ws.cancel = cancel
+	met := fs.watchdog(dataOp, cancel)
+	defer func() {
+		if !met() {
+			err = errStalled
+		}
+	}()
+	stream, err := fs.client.WriteStream(ctx)
+	if err != nil {
+		return err
+	}
+	ws.stream = stream
+	chunk := fs.maxWriteChunk()
+	for off := 0; off < len(ws.unacked); off += chunk {
+		end := off + chunk
+		if end > len(ws.unacked) {
+			end = len(ws.unacked)
+		}
+		req := &pb.WriteStreamRequest{
+			Handle: handle,
+			Offset: ws.acked + int64(off),
+			Data:   ws.unacked[off:end],
+		}
+		if err := stream.Send(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *grpcFile) openWriteStream(off int64) error {
+	ws := &writeStream{acked: off}
+	if err := ws.start(f.fs, f.name, f.handle); err != nil {
+		ws.cancel()
+		return err
+	}
+	f.wstream = ws
+	return nil
+}
+
+// finish waits for the server to acknowledge everything sent over the
+// stream. If the stream broke, the unacknowledged data is sent again from
+// the last acknowledged offset, unless the server stopped responding.
+func (ws *writeStream) finish(fs *GrpcFs, name string, handle uint64) (*pb.WriteStreamResponse, error) {
+	var (
+		resp *pb.WriteStreamResponse
+		err  error
+	)
+	for attempt := 0; attempt <= writeStreamRetries && grpc.Code(err) != codes.DeadlineExceeded; attempt++ {
+		if attempt > 0 {
+			ws.cancel()
+			if err = ws.start(fs, name, handle); err != nil {
+				continue
+			}
+		}
+		met := fs.watchdog(dataOp, ws.cancel)
+		resp, err = ws.stream.CloseAndRecv()
+		if !met() {
+			err = errStalled
+		}
+		if err == nil {
+			break
+		}
+	}
+	ws.cancel()
+	return resp, err
+}
+
+// commitWrites waits for the server to acknowledge everything sent over
+// the current WriteStream, retrying according to the mount mode.
+func (f *grpcFile) commitWrites() fuse.Status {
+	ws := f.wstream
+	if ws == nil {
+		return fuse.OK
+	}
+	f.wstream = nil
+	defer f.fs.attrs.invalidate(f.name)
+	var resp *pb.WriteStreamResponse
+	for attempt := 0; ; attempt++ {
+		handle := f.handle
+		// A broken stream is sent again as a whole, which writes the
+		// same data at the same offsets, so it can be repeated.
+		err := f.fs.retry(nil, true, func() (sent bool, err error) {
+			resp, err = ws.finish(f.fs, f.name, handle)
+			return true, err
+		})
+		if err != nil {
+			log.Printf("Error writing to stream for handle %d: %v", handle, err)
+			return toStatus(err)
+		}
+		if !stale(resp.Status.Code) || attempt > 0 {
+			break
+		}
+		// Everything since the stream was opened is sent again on
+		// the new handle.
+		if code := f.reopen(handle); code != fuse.OK {
+			return code
+		}
+		if err := ws.start(f.fs, f.name, f.handle); err != nil {
+			// finish starts it again.
+			log.Printf("Error opening write stream for handle %d: %v", f.handle, err)
+		}
+	}
+	if code := statusCode(resp.Status); code != fuse.OK {
+		return code
+	}
+	if resp.Offset != ws.end() {
+		return fuse.EIO
+	}
+	return fuse.OK
+}
+
+func (f *grpcFile) Read(dest []byte, off int64) (fuse.ReadResult, fuse.Status) {
+	f.mu.Lock()
+	if code := f.commitWrites(); code != fuse.OK {
+		f.mu.Unlock()
+		return nil, code
+	}
+	if f.stream != nil && f.stream.off != off {
+		f.closeStream()
+	}
+	if f.stream == nil && off > 0 && off == f.nextOff && f.fs.has(pb.Feature_StreamRead) {
+		if err := f.openStream(off); err != nil {
+			log.Printf("Error opening read stream for handle %d: %v", f.handle, err)
+		}
+	}
+	if f.stream != nil {
+		met := f.fs.watchdog(dataOp, f.stream.cancel)
+		n, code := f.stream.read(dest)
+		stalled := !met()
+		if stalled && f.fs.mount == NoRetry {
+			f.closeStream()
+			f.mu.Unlock()
+			return nil, toStatus(errStalled)
+		}
+		if !stalled && code == fuse.OK {
+			f.nextOff = off + int64(n)
+			if f.stream.eof {
+				// The file might grow, so later reads have to
+				// ask the server again.
+				f.closeStream()
+			}
+			f.mu.Unlock()
+			return fuse.ReadResultData(dest[:n]), fuse.OK
+		}
+		// Fall back to a plain read, which reports the error
+		// again if it wasn't specific to the stream, or is retried.
+		log.Printf("Error reading from stream for handle %d: %v", f.handle, code)
+		f.closeStream()
+	}
+	f.nextOff = off + int64(len(dest))
+	f.mu.Unlock()
+
+	var resp *pb.ReadResponse
+	code := f.callHandle("Read", func(rctx context.Context, handle uint64, opts ...grpc.CallOption) (*pb.Status, error) {
+		req := &pb.ReadRequest{
+			Handle: handle,
+			Offset: off,
+			Size_:  uint32(len(dest)),
+		}
+		var err error
+		if resp, err = f.fs.client.Read(rctx, req, opts...); err != nil {
+			return nil, err
+		}
+		return resp.Status, nil
+	})
+	if code != fuse.OK {
+		return nil, code
+	}
+	return fuse.ReadResultData(resp.Data), fuse.OK
+}
+
+func (f *grpcFile) Write(data []byte, off int64) (uint32, fuse.Status) {
+	f.mu.Lock()
+	// Data already streamed might be overwritten.
+	f.closeStream()
+	if f.wstream != nil && f.wstream.end() != off {
+		if code := f.commitWrites(); code != fuse.OK {
+			f.mu.Unlock()
+			return 0, code
+		}
+	}
+	if f.wstream == nil && off > 0 && off == f.nextWriteOff && f.fs.has(pb.Feature_StreamWrite) {
+		if err := f.openWriteStream(off); err != nil {
+			log.Printf("Error opening write stream for handle %d: %v", f.handle, err)
+		}
+	}
+	f.nextWriteOff = off + int64(len(data))
+	if ws := f.wstream; ws != nil {
+		ws.unacked = append(ws.unacked, data...)
+		req := &pb.WriteStreamRequest{
+			Handle: f.handle,
+			Offset: off,
+			Data:   data,
+		}
+		// A failed Send means that the stream broke; commitWrites
+		// resumes it.
+		code := fuse.OK
+		met := f.fs.watchdog(dataOp, ws.cancel)
+		if err := ws.stream.Send(req); !met() || err != nil || len(ws.unacked) >= maxUnackedWrite {
+			code = f.commitWrites()
+		}
+		f.mu.Unlock()
+		if code != fuse.OK {
+			return 0, code
+		}
+		return uint32(len(data)), fuse.OK
+	}
+	f.mu.Unlock()
+
+	var resp *pb.WriteResponse
+	code := f.callHandle("Write", func(rctx context.Context, handle uint64, opts ...grpc.CallOption) (*pb.Status, error) {
+		req := &pb.WriteRequest{
+			Handle: handle,
+			Offset: off,
+			Data:   data,
+		}
+		var err error
+		if resp, err = f.fs.client.Write(rctx, req, opts...); err != nil {
+			return nil, err
+		}
+		return resp.Status, nil
+	})
+	f.fs.attrs.invalidate(f.name)
+	if code != fuse.OK {
+		return 0, code
+	}
+	return resp.Written, fuse.OK
+}
+
+// GetAttr sends out buffered writes, so the path based GetAttr which pathfs
+// falls back to reports the current size.
+func (f *grpcFile) GetAttr(out *fuse.Attr) fuse.Status {
+	f.mu.Lock()
+	code := f.commitWrites()
+	f.mu.Unlock()
+	if code != fuse.OK {
+		return code
+	}
+	return fuse.ENOSYS
+}
+
+// Truncate sends out buffered writes, so they can't land after the path
+// based Truncate which pathfs falls back to.
+func (f *grpcFile) Truncate(size uint64) fuse.Status {
+	f.mu.Lock()
+	code := f.commitWrites()
+	f.mu.Unlock()
+	if code != fuse.OK {
+		return code
+	}
+	return fuse.ENOSYS
+}
+
+func (f *grpcFile) Flush() fuse.Status {
+	f.mu.Lock()
+	code := f.commitWrites()
+	f.mu.Unlock()
+	if code != fuse.OK {
+		return code
+	}
+
+	return f.callHandle("Flush", func(rctx context.Context, handle uint64, opts ...grpc.CallOption) (*pb.Status, error) {
+		req := &pb.FlushRequest{
+			Handle: handle,
+		}
+		resp, err := f.fs.client.Flush(rctx, req, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Status, nil
+	})
+}
+
+func (f *grpcFile) Fsync(flags int) fuse.Status {
+	f.mu.Lock()
+	code := f.commitWrites()
+	f.mu.Unlock()
+	if code != fuse.OK {
+		return code
+	}
+
+	return f.callHandle("Fsync", func(rctx context.Context, handle uint64, opts ...grpc.CallOption) (*pb.Status, error) {
+		req := &pb.FsyncRequest{
+			Handle: handle,
+			Flags:  flags,
+		}
+		resp, err := f.fs.client.Fsync(rctx, req, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Status, nil
+	})
+}
+
+func (f *grpcFile) Release() {
+	f.mu.Lock()
+	f.closeStream()
+	if code := f.commitWrites(); code != fuse.OK {
+		log.Printf("Error writing buffered data for handle %d: %v", f.handle, code)
+	}
+	req := &pb.ReleaseRequest{
+		Handle: f.handle,
+	}
+	f.mu.Unlock()
+
+	var resp *pb.ReleaseResponse
+	err := f.fs.call(dataOp, nil, "Release", f.name, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = f.fs.client.Release(rctx, req, opts...)
+		return resp.GetStatus(), err
+	})
+	if err != nil {
+		log.Printf("Error releasing file handle %d: %v", req.Handle, err)
+	} else if resp.Status != nil {
+		// Older servers don't send one.
+		statusCode(resp.Status)
+	}
+}
@@ -0,0 +1,224 @@
+package grpcfs
+
+import (
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/LK4D4/grfuse/pb"
+	"github.com/LK4D4/grfuse/server"
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/nodefs"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func dialFs(t *testing.T, srv pb.PathFSServer) (*GrpcFs, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	pb.RegisterPathFSServer(s, srv)
+	go s.Serve(l)
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		s.Stop()
+		t.Fatal(err)
+	}
+	return New(pb.NewPathFSClient(conn)), func() {
+		conn.Close()
+		s.Stop()
+	}
+}
+
+// growingFs serves "log", which can be appended to.
+type growingFs struct {
+	pathfs.FileSystem
+	mu   sync.Mutex
+	data []byte
+}
+
+func (fs *growingFs) append(b string) {
+	fs.mu.Lock()
+	fs.data = append(fs.data, b...)
+	fs.mu.Unlock()
+}
+
+func (fs *growingFs) Open(name string, flags uint32, context *fuse.Context) (nodefs.File, fuse.Status) {
+	return &growingFile{File: nodefs.NewDefaultFile(), fs: fs}, fuse.OK
+}
+
+type growingFile struct {
+	nodefs.File
+	fs *growingFs
+}
+
+func (f *growingFile) Read(dest []byte, off int64) (fuse.ReadResult, fuse.Status) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if off >= int64(len(f.fs.data)) {
+		return fuse.ReadResultData(nil), fuse.OK
+	}
+	n := copy(dest, f.fs.data[off:])
+	return fuse.ReadResultData(dest[:n]), fuse.OK
+}
+
+func (f *growingFile) Write(data []byte, off int64) (uint32, fuse.Status) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if end := off + int64(len(data)); end > int64(len(f.fs.data)) {
+		f.fs.data = append(f.fs.data, make([]byte, end-int64(len(f.fs.data)))...)
+	}
+	copy(f.fs.data[off:], data)
+	return uint32(len(data)), fuse.OK
+}
+
+func TestReadAfterAppend(t *testing.T) {
+	backend := &growingFs{FileSystem: pathfs.NewDefaultFileSystem()}
+	backend.append("hello world")
+	fs, stop := dialFs(t, server.New(backend))
+	defer stop()
+
+	f, code := fs.Open("log", uint32(os.O_RDONLY), &fuse.Context{})
+	if code != fuse.OK {
+		t.Fatal(code)
+	}
+	defer f.Release()
+	read := func(off int64, size int) string {
+		buf := make([]byte, size)
+		res, code := f.Read(buf, off)
+		if code != fuse.OK {
+			t.Fatal(code)
+		}
+		data, _ := res.Bytes(buf)
+		return string(data)
+	}
+	if got := read(0, 5); got != "hello" {
+		t.Fatalf("read %q", got)
+	}
+	// Sequential reads are served by a stream from now on, which hits
+	// the end of file.
+	if got := read(5, 100); got != " world" {
+		t.Fatalf("read %q", got)
+	}
+	backend.append(", again")
+	if got := read(11, 100); got != ", again" {
+		t.Fatalf("read %q after append, want %q", got, ", again")
+	}
+	backend.append("!")
+	if got := read(18, 100); got != "!" {
+		t.Fatalf("read %q after second append, want %q", got, "!")
+	}
+}
+
+// breakingServer loses the response of the first WriteStream, as if the
+// connection broke, and records the offsets streams start at.
+type breakingServer struct {
+	pb.PathFSServer
+	mu     sync.Mutex
+	starts []int64
+}
+
+func (s *breakingServer) WriteStream(stream pb.PathFS_WriteStreamServer) error {
+	r, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.starts = append(s.starts, r.Offset)
+	first := len(s.starts) == 1
+	s.mu.Unlock()
+	if first {
+		for err == nil {
+			_, err = stream.Recv()
+		}
+		return grpc.Errorf(codes.Unavailable, "connection lost")
+	}
+	return s.PathFSServer.WriteStream(&replayStream{PathFS_WriteStreamServer: stream, first: r})
+}
+
+// replayStream receives first again before the rest of the stream.
+type replayStream struct {
+	pb.PathFS_WriteStreamServer
+	first *pb.WriteStreamRequest
+}
+
+func (s *replayStream) Recv() (*pb.WriteStreamRequest, error) {
+	if r := s.first; r != nil {
+		s.first = nil
+		return r, nil
+	}
+	return s.PathFS_WriteStreamServer.Recv()
+}
+
+func TestWriteStreamResume(t *testing.T) {
+	backend := &growingFs{FileSystem: pathfs.NewDefaultFileSystem()}
+	srv := &breakingServer{PathFSServer: server.New(backend)}
+	fs, stop := dialFs(t, srv)
+	defer stop()
+
+	f, code := fs.Open("log", uint32(os.O_WRONLY), &fuse.Context{})
+	if code != fuse.OK {
+		t.Fatal(code)
+	}
+	defer f.Release()
+	// The first write is sent on its own, the others are streamed.
+	for i, s := range []string{"hello", " wide", " world"} {
+		if _, code := f.Write([]byte(s), int64(i*5)); code != fuse.OK {
+			t.Fatal(code)
+		}
+	}
+	if code := f.Flush(); code != fuse.OK {
+		t.Fatalf("flushing after the stream broke: %v", code)
+	}
+	if got := string(backend.data); got != "hello wide world" {
+		t.Fatalf("wrote %q", got)
+	}
+	// Nothing was acknowledged before the stream broke, so all of it
+	// is sent again.
+	if len(srv.starts) != 2 || srv.starts[0] != 5 || srv.starts[1] != 5 {
+		t.Fatalf("streams started at %v, want twice at 5", srv.starts)
+	}
+}
+
+// forgettingServer loses the handle of the first Read, as if it had
+// restarted.
+type forgettingServer struct {
+	pb.PathFSServer
+	once sync.Once
+}
+
+func (s *forgettingServer) Read(ctx context.Context, r *pb.ReadRequest) (*pb.ReadResponse, error) {
+	forgot := false
+	s.once.Do(func() { forgot = true })
+	if forgot {
+		return &pb.ReadResponse{Status: &pb.Status{Code: fuse.Status(syscall.ESTALE)}}, nil
+	}
+	return s.PathFSServer.Read(ctx, r)
+}
+
+func TestReadAfterReopen(t *testing.T) {
+	backend := &growingFs{FileSystem: pathfs.NewDefaultFileSystem()}
+	backend.append("hello")
+	fs, stop := dialFs(t, &forgettingServer{PathFSServer: server.New(backend)})
+	defer stop()
+
+	f, code := fs.Open("log", uint32(os.O_RDONLY), &fuse.Context{})
+	if code != fuse.OK {
+		t.Fatal(code)
+	}
+	defer f.Release()
+	buf := make([]byte, 5)
+	res, code := f.Read(buf, 0)
+	if code != fuse.OK {
+		t.Fatal(code)
+	}
+	if data, _ := res.Bytes(buf); string(data) != "hello" {
+		t.Fatalf("read %q after reopening", data)
+	}
+}
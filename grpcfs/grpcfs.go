@@ -1,7 +1,10 @@
 package grpcfs
 
 import (
+	"io"
 	"log"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/LK4D4/grfuse/pb"
@@ -9,15 +12,129 @@ import (
 	"github.com/hanwen/go-fuse/fuse/nodefs"
 	"github.com/hanwen/go-fuse/fuse/pathfs"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
 )
 
 type GrpcFs struct {
 	client pb.PathFSClient
+	attrs  *attrCache
+
+	metadataTimeout time.Duration
+	dataTimeout     time.Duration
+	mount           MountMode
+	retries         int
+	retryTimeout    time.Duration
+	intr            bool
+
+	watch     bool
+	stopWatch context.CancelFunc
+
+	owners *ownerMap
+
+	sessMu      sync.Mutex
+	sess        *session
+	lastSession uint64
+	// helloDone is closed when the Hello in flight, if any, ends.
+	helloDone chan struct{}
+	// helloRetry is when to say Hello again after it failed.
+	helloRetry   time.Time
+	helloBackoff time.Duration
+
+	// debug is set while debugging, accessed atomically.
+	debug        int32
+	forwardDebug bool
+	clientID     string
+	lastRequest  uint64
+}
+
+// Options configures a GrpcFs.
+type Options struct {
+	// AttrTimeout is how long attributes returned by the server are
+	// cached. Changes made through this GrpcFs invalidate the cache,
+	// changes made by others are seen once the attributes expire.
+	// Zero disables caching.
+	AttrTimeout time.Duration
+	// NegativeTimeout is how long paths the server reported as not
+	// existing are remembered. Creating them through this GrpcFs
+	// invalidates the cache. Zero disables caching.
+	NegativeTimeout time.Duration
+	// Watch makes the file system subscribe to changes made on the
+	// server by other clients once mounted, and drop cached
+	// attributes, directory entries and file contents they affect,
+	// both its own and the kernel's.
+	Watch bool
+	// MetadataTimeout bounds calls working on names and attributes,
+	// DataTimeout calls reading and writing file contents. For streams
+	// they bound the wait for each message. A call which runs out of
+	// time fails with ETIMEDOUT. Zero means no deadline.
+	//
+	// go-fuse doesn't pass FUSE INTERRUPT requests on to the file
+	// system, so interrupted processes wait for the deadline unless
+	// Intr is set.
+	MetadataTimeout time.Duration
+	DataTimeout     time.Duration
+	// Mount selects how calls failing because the server can't be
+	// reached or doesn't answer in time are retried. Calls changing the
+	// file system are only retried if they never reached the server,
+	// as one which timed out or lost its connection may have been run
+	// anyway, and fail with EIO otherwise. The connection
+	// itself is re-established by the grpc client; files opened before
+	// the server restarted are opened again on first use.
+	Mount MountMode
+	// Retries and RetryTimeout limit the retries of a SoftMount. A
+	// zero RetryTimeout doesn't limit the time spent retrying.
+	Retries      int
+	RetryTimeout time.Duration
+	// Intr lets signals abort calls: a call, running or waiting to be
+	// retried, fails with EINTR once the process it is made for has a
+	// signal pending, which is when the kernel sends a FUSE INTERRUPT.
+	// Only calls by path can be aborted, as go-fuse doesn't tell which
+	// process reads or writes an open file.
+	Intr bool
+	// ForwardDebug makes SetDebug turn on debugging on the server too,
+	// if the server allows the client to. Calls then carry a request
+	// id, which both sides log.
+	ForwardDebug bool
+	// Owners changes who files appear to be owned by. May be nil.
+	Owners *OwnerMap
 }
 
+// MountMode is the retry behaviour of a GrpcFs, named after the NFS mount
+// options.
+type MountMode int
+
+const (
+	// NoRetry reports failures right away.
+	NoRetry MountMode = iota
+	// SoftMount retries failed calls up to Options.Retries times and
+	// for at most Options.RetryTimeout, then fails them with EIO.
+	SoftMount
+	// HardMount retries failed calls until the server answers.
+	HardMount
+)
+
 func New(c pb.PathFSClient) *GrpcFs {
+	return NewWithOptions(c, Options{})
+}
+
+func NewWithOptions(c pb.PathFSClient, opts Options) *GrpcFs {
 	return &GrpcFs{
 		client: c,
+		attrs:  newAttrCache(opts.AttrTimeout, opts.NegativeTimeout),
+
+		metadataTimeout: opts.MetadataTimeout,
+		dataTimeout:     opts.DataTimeout,
+		mount:           opts.Mount,
+		retries:         opts.Retries,
+		retryTimeout:    opts.RetryTimeout,
+		intr:            opts.Intr,
+
+		watch: opts.Watch,
+
+		owners: newOwnerMap(opts.Owners),
+
+		forwardDebug: opts.ForwardDebug,
+		clientID:     newClientID(),
 	}
 }
 
@@ -34,60 +151,144 @@ func pbContext(ctx *fuse.Context) *pb.Context {
 	}
 }
 
+// fuseAttr returns the attributes a sent by the server, with the owner
+// mapped to a local one.
+func (fs *GrpcFs) fuseAttr(a *pb.Attr) *fuse.Attr {
+	attr := &fuse.Attr{
+		Ino:       a.Ino,
+		Size:      a.SizeAttr,
+		Blocks:    a.Blocks,
+		Atime:     a.Atime,
+		Mtime:     a.Mtime,
+		Ctime:     a.Ctime,
+		Atimensec: a.Atimensec,
+		Mtimensec: a.Mtimensec,
+		Ctimensec: a.Ctimensec,
+		Mode:      a.Mode,
+		Nlink:     a.Nlink,
+		Rdev:      a.Rdev,
+		Blksize:   a.Blksize,
+		Padding:   a.Padding,
+	}
+	if a.Owner != nil {
+		attr.Owner = fuse.Owner{
+			Uid: a.Owner.Uid,
+			Gid: a.Owner.Gid,
+		}
+	}
+	attr.Owner = fs.owners.local(attr.Owner)
+	return attr
+}
+
 func (fs *GrpcFs) GetAttr(name string, ctx *fuse.Context) (*fuse.Attr, fuse.Status) {
+	if attr, code, ok := fs.attrs.get(name); ok {
+		return attr, code
+	}
 	req := &pb.GetAttrRequest{
 		Name:    name,
 		Context: pbContext(ctx),
 	}
-	resp, err := fs.client.GetAttr(context.Background(), req)
+	var resp *pb.GetAttrResponse
+	err := fs.call(metadataOp, ctx, "GetAttr", req.Name, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.GetAttr(rctx, req, opts...)
+		return resp.GetStatus(), err
+	})
 	if err != nil {
-		return nil, fuse.ToStatus(err)
-	}
-	if resp.Status.Code != fuse.OK {
-		return nil, resp.Status.Code
-	}
-	return &fuse.Attr{
-		Ino:       resp.Attr.Ino,
-		Size:      resp.Attr.SizeAttr,
-		Blocks:    resp.Attr.Blocks,
-		Atime:     resp.Attr.Atime,
-		Mtime:     resp.Attr.Mtime,
-		Ctime:     resp.Attr.Ctime,
-		Atimensec: resp.Attr.Atimensec,
-		Mtimensec: resp.Attr.Mtimensec,
-		Ctimensec: resp.Attr.Ctimensec,
-		Mode:      resp.Attr.Mode,
-		Nlink:     resp.Attr.Nlink,
-		Owner: fuse.Owner{
-			Uid: resp.Attr.Owner.Uid,
-			Gid: resp.Attr.Owner.Gid,
-		},
-		Rdev:    resp.Attr.Rdev,
-		Blksize: resp.Attr.Blksize,
-		Padding: resp.Attr.Padding,
-	}, fuse.OK
+		return nil, toStatus(err)
+	}
+	if resp.Status.Code == fuse.ENOENT {
+		fs.attrs.setNegative(name)
+	}
+	if code := statusCode(resp.Status); code != fuse.OK {
+		return nil, code
+	}
+	attr := fs.fuseAttr(resp.Attr)
+	fs.attrs.set(name, attr)
+	return attr, fuse.OK
 }
 
 func (fs *GrpcFs) OpenDir(name string, ctx *fuse.Context) ([]fuse.DirEntry, fuse.Status) {
 	req := &pb.OpenDirRequest{
 		Name:    name,
 		Context: pbContext(ctx),
-	}
-	resp, err := fs.client.OpenDir(context.Background(), req)
+		// Listing a directory is usually followed by GetAttr for
+		// its entries, have them cached right away.
+		Plus: fs.attrs.ttl > 0 && fs.has(pb.Feature_DirPlus),
+	}
+	if !fs.has(pb.Feature_StreamDir) {
+		var resp *pb.OpenDirResponse
+		err := fs.call(metadataOp, ctx, "OpenDir", req.Name, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+			resp, err = fs.client.OpenDir(rctx, req, opts...)
+			return resp.GetStatus(), err
+		})
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		if code := statusCode(resp.Status); code != fuse.OK {
+			return nil, code
+		}
+		return fs.dirEntries(name, resp.Dirs), fuse.OK
+	}
+	var (
+		c    []fuse.DirEntry
+		code fuse.Status
+	)
+	id := fs.requestID()
+	err := fs.retry(ctx, idempotentOps["OpenDirStream"], func() (sent bool, err error) {
+		start := time.Now()
+		c, code, err = fs.readDir(req, id)
+		fs.debugCall(id, "OpenDirStream", name, start, code, err)
+		return true, err
+	})
 	if err != nil {
-		return nil, fuse.ToStatus(err)
-	}
-	if resp.Status.Code != fuse.OK {
-		return nil, resp.Status.Code
+		return nil, toStatus(err)
 	}
-	var c []fuse.DirEntry
-	for _, dir := range resp.Dirs {
+	return c, code
+}
+
+// dirEntries converts the entries of directory name, caching their
+// attributes if they were sent.
+func (fs *GrpcFs) dirEntries(name string, dirs []*pb.DirEntry) []fuse.DirEntry {
+	c := make([]fuse.DirEntry, 0, len(dirs))
+	for _, dir := range dirs {
 		c = append(c, fuse.DirEntry{
 			Name: dir.Name,
 			Mode: dir.Mode,
 		})
+		if dir.Attr != nil {
+			fs.attrs.set(filepath.Join(name, dir.Name), fs.fuseAttr(dir.Attr))
+		}
+	}
+	return c
+}
+
+// readDir receives the entries of a directory over an OpenDirStream.
+func (fs *GrpcFs) readDir(req *pb.OpenDirRequest, id string) ([]fuse.DirEntry, fuse.Status, error) {
+	sctx, cancel := context.WithCancel(fs.outgoing(context.Background(), id))
+	defer cancel()
+	stream, err := fs.client.OpenDirStream(sctx, req)
+	if err != nil {
+		return nil, fuse.OK, err
+	}
+	var c []fuse.DirEntry
+	for {
+		met := fs.watchdog(metadataOp, cancel)
+		resp, err := stream.Recv()
+		if !met() {
+			return nil, fuse.OK, errStalled
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fuse.OK, err
+		}
+		if code := statusCode(resp.Status); code != fuse.OK {
+			return nil, code, nil
+		}
+		c = append(c, fs.dirEntries(req.Name, resp.Dirs)...)
 	}
-	return c, fuse.OK
+	return c, fuse.OK, nil
 }
 
 func (fs *GrpcFs) Open(name string, flags uint32, ctx *fuse.Context) (nodefs.File, fuse.Status) {
@@ -95,19 +296,35 @@ func (fs *GrpcFs) Open(name string, flags uint32, ctx *fuse.Context) (nodefs.Fil
 		Name:    name,
 		Flags:   flags,
 		Context: pbContext(ctx),
+		Handle:  true,
+	}
+	var resp *pb.OpenResponse
+	err := fs.call(metadataOp, ctx, "Open", req.Name, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.Open(rctx, req, opts...)
+		return resp.GetStatus(), err
+	})
+	if flags&fuse.O_ANYWRITE != 0 {
+		fs.attrs.invalidate(name)
 	}
-	resp, err := fs.client.Open(context.Background(), req)
 	if err != nil {
-		return nil, fuse.ToStatus(err)
+		return nil, toStatus(err)
 	}
-	if resp.Status.Code != fuse.OK {
-		return nil, resp.Status.Code
+	if code := statusCode(resp.Status); code != fuse.OK {
+		return nil, code
 	}
-	return nodefs.NewDataFile(resp.File.Data), fuse.OK
+	if resp.File.Handle == 0 {
+		// Servers from before handles send the contents instead.
+		return nodefs.NewDataFile(resp.File.Data), fuse.OK
+	}
+	return newFile(fs, name, flags, req.Context, resp.File.Handle), fuse.OK
 }
 
 func (fs *GrpcFs) String() string {
-	resp, err := fs.client.String(context.Background(), nil)
+	var resp *pb.StringResponse
+	err := fs.call(metadataOp, nil, "String", "", func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.String(rctx, nil, opts...)
+		return nil, err
+	})
 	if err != nil {
 		log.Printf("Error calling string method: %v", err)
 		return ""
@@ -115,35 +332,45 @@ func (fs *GrpcFs) String() string {
 	return resp.String_
 }
 
-func (fs *GrpcFs) SetDebug(debug bool) {
-	return
-}
-
 func (fs *GrpcFs) Chmod(name string, mode uint32, ctx *fuse.Context) fuse.Status {
 	req := &pb.ChmodRequest{
 		Name:    name,
 		Mode:    mode,
 		Context: pbContext(ctx),
 	}
-	resp, err := fs.client.Chmod(context.Background(), req)
+	var resp *pb.ChmodResponse
+	err := fs.call(metadataOp, ctx, "Chmod", req.Name, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.Chmod(rctx, req, opts...)
+		return resp.GetStatus(), err
+	})
+	fs.attrs.invalidate(name)
 	if err != nil {
-		return fuse.ToStatus(err)
+		return toStatus(err)
 	}
-	return resp.Status.Code
+	return statusCode(resp.Status)
 }
 
 func (fs *GrpcFs) Chown(name string, uid uint32, gid uint32, ctx *fuse.Context) fuse.Status {
+	uid, gid, ok := fs.owners.remote(uid, gid)
+	if !ok {
+		return fuse.EPERM
+	}
 	req := &pb.ChownRequest{
 		Name:    name,
 		UID:     uid,
 		GID:     gid,
 		Context: pbContext(ctx),
 	}
-	resp, err := fs.client.Chown(context.Background(), req)
+	var resp *pb.ChownResponse
+	err := fs.call(metadataOp, ctx, "Chown", req.Name, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.Chown(rctx, req, opts...)
+		return resp.GetStatus(), err
+	})
+	fs.attrs.invalidate(name)
 	if err != nil {
-		return fuse.ToStatus(err)
+		return toStatus(err)
 	}
-	return resp.Status.Code
+	return statusCode(resp.Status)
 }
 
 func (fs *GrpcFs) Utimens(name string, Atime *time.Time, Mtime *time.Time, ctx *fuse.Context) fuse.Status {
@@ -153,11 +380,16 @@ func (fs *GrpcFs) Utimens(name string, Atime *time.Time, Mtime *time.Time, ctx *
 		Mtime:   Mtime.UnixNano(),
 		Context: pbContext(ctx),
 	}
-	resp, err := fs.client.Utimens(context.Background(), req)
+	var resp *pb.UtimensResponse
+	err := fs.call(metadataOp, ctx, "Utimens", req.Name, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.Utimens(rctx, req, opts...)
+		return resp.GetStatus(), err
+	})
+	fs.attrs.invalidate(name)
 	if err != nil {
-		return fuse.ToStatus(err)
+		return toStatus(err)
 	}
-	return resp.Status.Code
+	return statusCode(resp.Status)
 }
 
 func (fs *GrpcFs) Truncate(name string, size uint64, ctx *fuse.Context) fuse.Status {
@@ -166,11 +398,16 @@ func (fs *GrpcFs) Truncate(name string, size uint64, ctx *fuse.Context) fuse.Sta
 		Size_:   size,
 		Context: pbContext(ctx),
 	}
-	resp, err := fs.client.Truncate(context.Background(), req)
+	var resp *pb.TruncateResponse
+	err := fs.call(metadataOp, ctx, "Truncate", req.Name, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.Truncate(rctx, req, opts...)
+		return resp.GetStatus(), err
+	})
+	fs.attrs.invalidate(name)
 	if err != nil {
-		return fuse.ToStatus(err)
+		return toStatus(err)
 	}
-	return resp.Status.Code
+	return statusCode(resp.Status)
 }
 
 func (fs *GrpcFs) Access(name string, mode uint32, ctx *fuse.Context) fuse.Status {
@@ -179,11 +416,15 @@ func (fs *GrpcFs) Access(name string, mode uint32, ctx *fuse.Context) fuse.Statu
 		Mode:    mode,
 		Context: pbContext(ctx),
 	}
-	resp, err := fs.client.Access(context.Background(), req)
+	var resp *pb.AccessResponse
+	err := fs.call(metadataOp, ctx, "Access", req.Name, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.Access(rctx, req, opts...)
+		return resp.GetStatus(), err
+	})
 	if err != nil {
-		return fuse.ToStatus(err)
+		return toStatus(err)
 	}
-	return resp.Status.Code
+	return statusCode(resp.Status)
 }
 
 func (fs *GrpcFs) Link(oldName string, newName string, ctx *fuse.Context) fuse.Status {
@@ -192,11 +433,17 @@ func (fs *GrpcFs) Link(oldName string, newName string, ctx *fuse.Context) fuse.S
 		NewName: newName,
 		Context: pbContext(ctx),
 	}
-	resp, err := fs.client.Link(context.Background(), req)
+	var resp *pb.LinkResponse
+	err := fs.call(metadataOp, ctx, "Link", req.OldName, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.Link(rctx, req, opts...)
+		return resp.GetStatus(), err
+	})
+	fs.attrs.invalidate(oldName)
+	fs.attrs.invalidate(newName)
 	if err != nil {
-		return fuse.ToStatus(err)
+		return toStatus(err)
 	}
-	return resp.Status.Code
+	return statusCode(resp.Status)
 }
 
 func (fs *GrpcFs) Mkdir(name string, mode uint32, ctx *fuse.Context) fuse.Status {
@@ -205,11 +452,16 @@ func (fs *GrpcFs) Mkdir(name string, mode uint32, ctx *fuse.Context) fuse.Status
 		Mode:    mode,
 		Context: pbContext(ctx),
 	}
-	resp, err := fs.client.Mkdir(context.Background(), req)
+	var resp *pb.MkdirResponse
+	err := fs.call(metadataOp, ctx, "Mkdir", req.Name, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.Mkdir(rctx, req, opts...)
+		return resp.GetStatus(), err
+	})
+	fs.attrs.invalidate(name)
 	if err != nil {
-		return fuse.ToStatus(err)
+		return toStatus(err)
 	}
-	return resp.Status.Code
+	return statusCode(resp.Status)
 }
 
 func (fs *GrpcFs) Mknod(name string, mode uint32, dev uint32, ctx *fuse.Context) fuse.Status {
@@ -219,11 +471,16 @@ func (fs *GrpcFs) Mknod(name string, mode uint32, dev uint32, ctx *fuse.Context)
 		Dev:     dev,
 		Context: pbContext(ctx),
 	}
-	resp, err := fs.client.Mknod(context.Background(), req)
+	var resp *pb.MknodResponse
+	err := fs.call(metadataOp, ctx, "Mknod", req.Name, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.Mknod(rctx, req, opts...)
+		return resp.GetStatus(), err
+	})
+	fs.attrs.invalidate(name)
 	if err != nil {
-		return fuse.ToStatus(err)
+		return toStatus(err)
 	}
-	return resp.Status.Code
+	return statusCode(resp.Status)
 }
 
 func (fs *GrpcFs) Rename(oldName string, newName string, ctx *fuse.Context) fuse.Status {
@@ -232,11 +489,17 @@ func (fs *GrpcFs) Rename(oldName string, newName string, ctx *fuse.Context) fuse
 		NewName: newName,
 		Context: pbContext(ctx),
 	}
-	resp, err := fs.client.Rename(context.Background(), req)
+	var resp *pb.RenameResponse
+	err := fs.call(metadataOp, ctx, "Rename", req.OldName, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.Rename(rctx, req, opts...)
+		return resp.GetStatus(), err
+	})
+	fs.attrs.invalidateTree(oldName)
+	fs.attrs.invalidateTree(newName)
 	if err != nil {
-		return fuse.ToStatus(err)
+		return toStatus(err)
 	}
-	return resp.Status.Code
+	return statusCode(resp.Status)
 }
 
 func (fs *GrpcFs) Rmdir(name string, ctx *fuse.Context) fuse.Status {
@@ -244,11 +507,16 @@ func (fs *GrpcFs) Rmdir(name string, ctx *fuse.Context) fuse.Status {
 		Name:    name,
 		Context: pbContext(ctx),
 	}
-	resp, err := fs.client.Rmdir(context.Background(), req)
+	var resp *pb.RmdirResponse
+	err := fs.call(metadataOp, ctx, "Rmdir", req.Name, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.Rmdir(rctx, req, opts...)
+		return resp.GetStatus(), err
+	})
+	fs.attrs.invalidate(name)
 	if err != nil {
-		return fuse.ToStatus(err)
+		return toStatus(err)
 	}
-	return resp.Status.Code
+	return statusCode(resp.Status)
 }
 
 func (fs *GrpcFs) Unlink(name string, ctx *fuse.Context) fuse.Status {
@@ -256,11 +524,16 @@ func (fs *GrpcFs) Unlink(name string, ctx *fuse.Context) fuse.Status {
 		Name:    name,
 		Context: pbContext(ctx),
 	}
-	resp, err := fs.client.Unlink(context.Background(), req)
+	var resp *pb.UnlinkResponse
+	err := fs.call(metadataOp, ctx, "Unlink", req.Name, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.Unlink(rctx, req, opts...)
+		return resp.GetStatus(), err
+	})
+	fs.attrs.invalidate(name)
 	if err != nil {
-		return fuse.ToStatus(err)
+		return toStatus(err)
 	}
-	return resp.Status.Code
+	return statusCode(resp.Status)
 }
 
 func (fs *GrpcFs) GetXAttr(name string, attribute string, ctx *fuse.Context) ([]byte, fuse.Status) {
@@ -269,11 +542,15 @@ func (fs *GrpcFs) GetXAttr(name string, attribute string, ctx *fuse.Context) ([]
 		Attribute: attribute,
 		Context:   pbContext(ctx),
 	}
-	resp, err := fs.client.GetXAttr(context.Background(), req)
+	var resp *pb.GetXAttrResponse
+	err := fs.call(metadataOp, ctx, "GetXAttr", req.Name, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.GetXAttr(rctx, req, opts...)
+		return resp.GetStatus(), err
+	})
 	if err != nil {
-		return nil, fuse.ToStatus(err)
+		return nil, toStatus(err)
 	}
-	return resp.Data, resp.Status.Code
+	return resp.Data, statusCode(resp.Status)
 }
 
 func (fs *GrpcFs) ListXAttr(name string, ctx *fuse.Context) ([]string, fuse.Status) {
@@ -281,11 +558,15 @@ func (fs *GrpcFs) ListXAttr(name string, ctx *fuse.Context) ([]string, fuse.Stat
 		Name:    name,
 		Context: pbContext(ctx),
 	}
-	resp, err := fs.client.ListXAttr(context.Background(), req)
+	var resp *pb.ListXAttrResponse
+	err := fs.call(metadataOp, ctx, "ListXAttr", req.Name, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.ListXAttr(rctx, req, opts...)
+		return resp.GetStatus(), err
+	})
 	if err != nil {
-		return nil, fuse.ToStatus(err)
+		return nil, toStatus(err)
 	}
-	return resp.Attributes, resp.Status.Code
+	return resp.Attributes, statusCode(resp.Status)
 }
 
 func (fs *GrpcFs) RemoveXAttr(name string, attr string, ctx *fuse.Context) fuse.Status {
@@ -294,11 +575,16 @@ func (fs *GrpcFs) RemoveXAttr(name string, attr string, ctx *fuse.Context) fuse.
 		Attribute: attr,
 		Context:   pbContext(ctx),
 	}
-	resp, err := fs.client.RemoveXAttr(context.Background(), req)
+	var resp *pb.RemoveXAttrResponse
+	err := fs.call(metadataOp, ctx, "RemoveXAttr", req.Name, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.RemoveXAttr(rctx, req, opts...)
+		return resp.GetStatus(), err
+	})
+	fs.attrs.invalidate(name)
 	if err != nil {
-		return fuse.ToStatus(err)
+		return toStatus(err)
 	}
-	return resp.Status.Code
+	return statusCode(resp.Status)
 }
 
 func (fs *GrpcFs) SetXAttr(name string, attr string, data []byte, flags int, ctx *fuse.Context) fuse.Status {
@@ -309,11 +595,16 @@ func (fs *GrpcFs) SetXAttr(name string, attr string, data []byte, flags int, ctx
 		Flags:     flags,
 		Context:   pbContext(ctx),
 	}
-	resp, err := fs.client.SetXAttr(context.Background(), req)
+	var resp *pb.SetXAttrResponse
+	err := fs.call(metadataOp, ctx, "SetXAttr", req.Name, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.SetXAttr(rctx, req, opts...)
+		return resp.GetStatus(), err
+	})
+	fs.attrs.invalidate(name)
 	if err != nil {
-		return fuse.ToStatus(err)
+		return toStatus(err)
 	}
-	return resp.Status.Code
+	return statusCode(resp.Status)
 }
 
 func (fs *GrpcFs) Create(name string, flags uint32, mode uint32, ctx *fuse.Context) (nodefs.File, fuse.Status) {
@@ -323,11 +614,19 @@ func (fs *GrpcFs) Create(name string, flags uint32, mode uint32, ctx *fuse.Conte
 		Mode:    mode,
 		Context: pbContext(ctx),
 	}
-	resp, err := fs.client.Create(context.Background(), req)
+	var resp *pb.CreateResponse
+	err := fs.call(metadataOp, ctx, "Create", req.Name, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.Create(rctx, req, opts...)
+		return resp.GetStatus(), err
+	})
+	fs.attrs.invalidate(name)
 	if err != nil {
-		return nil, fuse.ToStatus(err)
+		return nil, toStatus(err)
+	}
+	if code := statusCode(resp.Status); code != fuse.OK {
+		return nil, code
 	}
-	return nodefs.NewDataFile(resp.File.Data), resp.Status.Code
+	return newFile(fs, name, flags, req.Context, resp.File.Handle), fuse.OK
 }
 
 func (fs *GrpcFs) Symlink(value string, linkName string, ctx *fuse.Context) fuse.Status {
@@ -336,11 +635,16 @@ func (fs *GrpcFs) Symlink(value string, linkName string, ctx *fuse.Context) fuse
 		LinkName: linkName,
 		Context:  pbContext(ctx),
 	}
-	resp, err := fs.client.Symlink(context.Background(), req)
+	var resp *pb.SymlinkResponse
+	err := fs.call(metadataOp, ctx, "Symlink", req.LinkName, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.Symlink(rctx, req, opts...)
+		return resp.GetStatus(), err
+	})
+	fs.attrs.invalidate(linkName)
 	if err != nil {
-		return fuse.ToStatus(err)
+		return toStatus(err)
 	}
-	return resp.Status.Code
+	return statusCode(resp.Status)
 }
 
 func (fs *GrpcFs) Readlink(name string, ctx *fuse.Context) (string, fuse.Status) {
@@ -348,19 +652,27 @@ func (fs *GrpcFs) Readlink(name string, ctx *fuse.Context) (string, fuse.Status)
 		Name:    name,
 		Context: pbContext(ctx),
 	}
-	resp, err := fs.client.Readlink(context.Background(), req)
+	var resp *pb.ReadlinkResponse
+	err := fs.call(metadataOp, ctx, "Readlink", req.Name, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.Readlink(rctx, req, opts...)
+		return resp.GetStatus(), err
+	})
 	if err != nil {
-		return "", fuse.ToStatus(err)
+		return "", toStatus(err)
 	}
-	return resp.Value, resp.Status.Code
+	return resp.Value, statusCode(resp.Status)
 }
 
 func (fs *GrpcFs) StatFs(name string) *fuse.StatfsOut {
 	req := &pb.StatFsRequest{
 		Name: name,
 	}
-	resp, err := fs.client.StatFs(context.Background(), req)
-	if err != nil {
+	var resp *pb.StatFsResponse
+	err := fs.call(metadataOp, nil, "StatFs", req.Name, func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.StatFs(rctx, req, opts...)
+		return nil, err
+	})
+	if err != nil || resp.StatFs == nil {
 		return nil
 	}
 	spare := [6]uint32{}
@@ -384,6 +696,17 @@ func (fs *GrpcFs) StatFs(name string) *fuse.StatfsOut {
 	}
 }
 
-func (fs *GrpcFs) OnMount(nodeFs *pathfs.PathNodeFs) {}
+func (fs *GrpcFs) OnMount(nodeFs *pathfs.PathNodeFs) {
+	if !fs.watch {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	fs.stopWatch = cancel
+	go fs.watchChanges(ctx, nodeFs)
+}
 
-func (fs *GrpcFs) OnUnmount() {}
+func (fs *GrpcFs) OnUnmount() {
+	if fs.stopWatch != nil {
+		fs.stopWatch()
+	}
+}
@@ -0,0 +1,170 @@
+package grpcfs
+
+import (
+	"log"
+	"time"
+
+	"github.com/LK4D4/grfuse/pb"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// protocolVersion is the version of the protocol spoken by the client.
+const protocolVersion = 1
+
+// clientFeatures lists the features the client can use.
+var clientFeatures = []pb.Feature{
+	pb.Feature_Handles,
+	pb.Feature_StaleHandles,
+	pb.Feature_StreamRead,
+	pb.Feature_StreamWrite,
+	pb.Feature_StreamDir,
+	pb.Feature_DirPlus,
+	pb.Feature_WatchChanges,
+	pb.Feature_ReadOnly,
+}
+
+// session is what the server told about itself in its Hello response.
+type session struct {
+	id       uint64
+	version  uint32
+	features map[pb.Feature]bool
+	limits   pb.Limits
+}
+
+// legacySession describes servers which don't implement Hello. They
+// have no features, Open sends the contents of files instead of handles.
+var legacySession = &session{
+	version:  1,
+	features: map[pb.Feature]bool{},
+}
+
+// minHelloBackoff and maxHelloBackoff bound the delay before saying
+// Hello again after it failed.
+const (
+	minHelloBackoff = time.Second
+	maxHelloBackoff = time.Minute
+)
+
+// session returns the session with the server, saying Hello first if
+// there is none yet. Concurrent callers wait for the same Hello, without
+// holding sessMu. If it fails, only the calls every server implements
+// are used until the next attempt, after a backoff.
+func (fs *GrpcFs) session() *session {
+	for {
+		fs.sessMu.Lock()
+		if fs.sess != nil {
+			s := fs.sess
+			fs.sessMu.Unlock()
+			return s
+		}
+		if time.Now().Before(fs.helloRetry) {
+			fs.sessMu.Unlock()
+			return legacySession
+		}
+		if done := fs.helloDone; done != nil {
+			fs.sessMu.Unlock()
+			<-done
+			continue
+		}
+		done := make(chan struct{})
+		fs.helloDone = done
+		fs.sessMu.Unlock()
+
+		s, err := fs.hello()
+
+		fs.sessMu.Lock()
+		fs.helloDone = nil
+		close(done)
+		if err != nil {
+			if fs.helloBackoff *= 2; fs.helloBackoff < minHelloBackoff {
+				fs.helloBackoff = minHelloBackoff
+			} else if fs.helloBackoff > maxHelloBackoff {
+				fs.helloBackoff = maxHelloBackoff
+			}
+			log.Printf("Error negotiating with server, trying again in %v: %v", fs.helloBackoff, err)
+			fs.helloRetry = time.Now().Add(fs.helloBackoff)
+			fs.sessMu.Unlock()
+			return legacySession
+		}
+		fs.helloBackoff = 0
+		if s != legacySession {
+			if fs.lastSession != 0 && fs.lastSession != s.id {
+				log.Printf("Server restarted, new session %x", s.id)
+			}
+			fs.lastSession = s.id
+		}
+		fs.sess = s
+		fs.sessMu.Unlock()
+		return s
+	}
+}
+
+// hello says Hello to the server and returns the session it starts, or
+// legacySession if the server doesn't implement Hello.
+func (fs *GrpcFs) hello() (*session, error) {
+	req := &pb.HelloRequest{
+		Version:  protocolVersion,
+		Features: clientFeatures,
+	}
+	var resp *pb.HelloResponse
+	err := fs.call(metadataOp, nil, "Hello", "", func(rctx context.Context, opts ...grpc.CallOption) (st *pb.Status, err error) {
+		resp, err = fs.client.Hello(rctx, req, opts...)
+		return nil, err
+	})
+	if grpc.Code(err) == codes.Unimplemented {
+		log.Printf("Server doesn't negotiate, using protocol version 1 without features")
+		return legacySession, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.Version > protocolVersion {
+		log.Printf("Server speaks protocol version %d, newer than %d", resp.Version, protocolVersion)
+	}
+	s := &session{
+		id:       resp.Session,
+		version:  resp.Version,
+		features: make(map[pb.Feature]bool),
+	}
+	for _, f := range resp.Features {
+		s.features[f] = true
+	}
+	if resp.Limits != nil {
+		s.limits = *resp.Limits
+	}
+	return s, nil
+}
+
+// resetSession makes the next call say Hello again, e.g. after the server
+// restarted, as it might have been replaced by another version.
+func (fs *GrpcFs) resetSession() {
+	fs.sessMu.Lock()
+	fs.sess = nil
+	fs.sessMu.Unlock()
+}
+
+// has reports whether the server supports feature f.
+func (fs *GrpcFs) has(f pb.Feature) bool {
+	return fs.session().features[f]
+}
+
+// ReadOnly reports whether the server exports the file system read-only,
+// in which case it should be mounted with the "ro" option.
+func (fs *GrpcFs) ReadOnly() bool {
+	return fs.has(pb.Feature_ReadOnly)
+}
+
+// messageOverhead is room left in messages for everything but file data.
+const messageOverhead = 4 << 10
+
+// maxWriteChunk returns the size of the largest chunk of data sent in a
+// single message.
+func (fs *GrpcFs) maxWriteChunk() int {
+	n := writeChunkSize
+	if max := int(fs.session().limits.MaxMessageSize) - messageOverhead; max > 0 && max < n {
+		n = max
+	}
+	return n
+}
@@ -0,0 +1,182 @@
+package grpcfs
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LK4D4/grfuse/pb"
+	"github.com/LK4D4/grfuse/server"
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// legacyServer is a server from before Hello and handles, which sends the
+// contents of files from Open.
+type legacyServer struct {
+	pb.PathFSServer
+}
+
+func (s legacyServer) Open(ctx context.Context, r *pb.OpenRequest) (*pb.OpenResponse, error) {
+	r.Handle = false
+	return s.PathFSServer.Open(ctx, r)
+}
+
+func (s legacyServer) Read(ctx context.Context, r *pb.ReadRequest) (*pb.ReadResponse, error) {
+	return nil, grpc.Errorf(codes.Unimplemented, "unknown method Read")
+}
+
+func (s legacyServer) ReadStream(r *pb.ReadStreamRequest, stream pb.PathFS_ReadStreamServer) error {
+	return grpc.Errorf(codes.Unimplemented, "unknown method ReadStream")
+}
+
+func (s legacyServer) Hello(ctx context.Context, r *pb.HelloRequest) (*pb.HelloResponse, error) {
+	return nil, grpc.Errorf(codes.Unimplemented, "unknown method Hello")
+}
+
+func (s legacyServer) OpenDirStream(r *pb.OpenDirRequest, stream pb.PathFS_OpenDirStreamServer) error {
+	return grpc.Errorf(codes.Unimplemented, "unknown method OpenDirStream")
+}
+
+func TestHello(t *testing.T) {
+	fs, stop := dialFs(t, server.New(&HelloFs{FileSystem: pathfs.NewDefaultFileSystem()}))
+	defer stop()
+
+	s := fs.session()
+	if s.id == 0 || s.version != protocolVersion {
+		t.Fatalf("unexpected session %+v", s)
+	}
+	for _, f := range clientFeatures {
+		if f == pb.Feature_ReadOnly {
+			// Only advertised by read-only exports.
+			continue
+		}
+		if !s.features[f] {
+			t.Errorf("server doesn't advertise %v", f)
+		}
+	}
+	if s.limits.MaxMessageSize == 0 || s.limits.MaxChunkSize == 0 {
+		t.Errorf("server doesn't advertise limits: %+v", s.limits)
+	}
+}
+
+func TestLegacyServer(t *testing.T) {
+	fs, stop := dialFs(t, legacyServer{server.New(&HelloFs{FileSystem: pathfs.NewDefaultFileSystem()})})
+	defer stop()
+
+	if fs.has(pb.Feature_StreamDir) {
+		t.Fatal("legacy server is assumed to stream directories")
+	}
+	c, code := fs.OpenDir("", nil)
+	if code != fuse.OK {
+		t.Fatal(code)
+	}
+	if len(c) != 1 || c[0].Name != "file.txt" {
+		t.Fatalf("unexpected entries %v", c)
+	}
+	f, code := fs.Open("file.txt", 0, nil)
+	if code != fuse.OK {
+		t.Fatal(code)
+	}
+	defer f.Release()
+	buf := make([]byte, 100)
+	res, code := f.Read(buf, 0)
+	if code != fuse.OK {
+		t.Fatal(code)
+	}
+	if data, _ := res.Bytes(buf); string(data) != "file.txt" {
+		t.Fatalf("read %q, want %q", data, "file.txt")
+	}
+}
+
+// flakyHelloServer fails Hello until told otherwise, and blocks it while
+// block is set.
+type flakyHelloServer struct {
+	pb.PathFSServer
+	mu    sync.Mutex
+	fail  bool
+	block chan struct{}
+	calls int
+}
+
+func (s *flakyHelloServer) Hello(ctx context.Context, r *pb.HelloRequest) (*pb.HelloResponse, error) {
+	s.mu.Lock()
+	s.calls++
+	fail, block := s.fail, s.block
+	s.mu.Unlock()
+	if block != nil {
+		<-block
+	}
+	if fail {
+		return nil, grpc.Errorf(codes.Internal, "not ready")
+	}
+	return s.PathFSServer.Hello(ctx, r)
+}
+
+func TestHelloBackoff(t *testing.T) {
+	srv := &flakyHelloServer{PathFSServer: server.New(&HelloFs{FileSystem: pathfs.NewDefaultFileSystem()}), fail: true}
+	fs, stop := dialFs(t, srv)
+	defer stop()
+
+	for i := 0; i < 3; i++ {
+		if s := fs.session(); s != legacySession {
+			t.Fatalf("session %+v after Hello failed", s)
+		}
+	}
+	if srv.calls != 1 {
+		t.Fatalf("said Hello %d times, want once until the backoff passes", srv.calls)
+	}
+
+	srv.fail = false
+	fs.sessMu.Lock()
+	fs.helloRetry = time.Time{}
+	fs.sessMu.Unlock()
+	if s := fs.session(); s == legacySession || s.id == 0 {
+		t.Fatalf("session %+v after the backoff", s)
+	}
+	if srv.calls != 2 {
+		t.Fatalf("said Hello %d times, want twice", srv.calls)
+	}
+}
+
+func TestHelloUnlocked(t *testing.T) {
+	block := make(chan struct{})
+	srv := &flakyHelloServer{PathFSServer: server.New(&HelloFs{FileSystem: pathfs.NewDefaultFileSystem()}), block: block}
+	fs, stop := dialFs(t, srv)
+	defer stop()
+
+	sessions := make(chan *session, 2)
+	for i := 0; i < 2; i++ {
+		go func() { sessions <- fs.session() }()
+	}
+	for {
+		srv.mu.Lock()
+		calls := srv.calls
+		srv.mu.Unlock()
+		if calls > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	reset := make(chan struct{})
+	go func() {
+		fs.resetSession()
+		close(reset)
+	}()
+	select {
+	case <-reset:
+	case <-time.After(time.Second):
+		t.Fatal("resetSession blocked by Hello in flight")
+	}
+	close(block)
+	a, b := <-sessions, <-sessions
+	if a != b || a == legacySession {
+		t.Fatalf("concurrent callers got sessions %+v and %+v, want the same", a, b)
+	}
+	if srv.calls != 1 {
+		t.Fatalf("said Hello %d times, want once", srv.calls)
+	}
+}
@@ -0,0 +1,103 @@
+package grpcfs
+
+import (
+	"os"
+
+	"github.com/hanwen/go-fuse/fuse"
+)
+
+// OwnerMap changes who files on the server appear to be owned by, e.g.
+// where clients and server don't share a passwd database. It only changes
+// the owners of files: calls are made as the local user, which the server
+// can map with server.IDMap.
+type OwnerMap struct {
+	// Mine presents all files as owned by the user and group the file
+	// system runs as. Chown to them leaves the owner on the server
+	// alone, and fails with EPERM for other ids.
+	Mine bool
+	// UIDs and GIDs map uids and gids of the server to local ones. Ids
+	// without an entry are left alone. Chown maps local ids back.
+	UIDs, GIDs map[uint32]uint32
+}
+
+// ownerMap is an OwnerMap ready for use.
+type ownerMap struct {
+	mine     bool
+	uid, gid uint32
+
+	uids, gids   map[uint32]uint32
+	ruids, rgids map[uint32]uint32
+}
+
+func newOwnerMap(m *OwnerMap) *ownerMap {
+	if m == nil {
+		return nil
+	}
+	return &ownerMap{
+		mine:  m.Mine,
+		uid:   uint32(os.Getuid()),
+		gid:   uint32(os.Getgid()),
+		uids:  m.UIDs,
+		gids:  m.GIDs,
+		ruids: reverseIDs(m.UIDs),
+		rgids: reverseIDs(m.GIDs),
+	}
+}
+
+func reverseIDs(ids map[uint32]uint32) map[uint32]uint32 {
+	r := make(map[uint32]uint32, len(ids))
+	for k, v := range ids {
+		r[v] = k
+	}
+	return r
+}
+
+func lookupID(ids map[uint32]uint32, id uint32) uint32 {
+	if mapped, ok := ids[id]; ok {
+		return mapped
+	}
+	return id
+}
+
+// local returns the local owner of a file owned by o on the server.
+func (m *ownerMap) local(o fuse.Owner) fuse.Owner {
+	if m == nil {
+		return o
+	}
+	if m.mine {
+		return fuse.Owner{Uid: m.uid, Gid: m.gid}
+	}
+	return fuse.Owner{
+		Uid: lookupID(m.uids, o.Uid),
+		Gid: lookupID(m.gids, o.Gid),
+	}
+}
+
+// unchangedID is the uid or gid given to Chown to leave it alone.
+const unchangedID = ^uint32(0)
+
+// remote returns the ids on the server of the local uid and gid given to
+// Chown. Files presented as the mounting user's can only be given to that
+// user, which leaves their owner on the server alone, so remote reports
+// false for other ids.
+func (m *ownerMap) remote(uid, gid uint32) (uint32, uint32, bool) {
+	if m == nil {
+		return uid, gid, true
+	}
+	if m.mine {
+		if uid == m.uid {
+			uid = unchangedID
+		}
+		if gid == m.gid {
+			gid = unchangedID
+		}
+		return uid, gid, uid == unchangedID && gid == unchangedID
+	}
+	if uid != unchangedID {
+		uid = lookupID(m.ruids, uid)
+	}
+	if gid != unchangedID {
+		gid = lookupID(m.rgids, gid)
+	}
+	return uid, gid, true
+}
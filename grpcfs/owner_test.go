@@ -0,0 +1,78 @@
+package grpcfs
+
+import (
+	"os"
+	"testing"
+
+	"github.com/LK4D4/grfuse/server"
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+)
+
+// ownerFs records the owner it's chowned to, and reports fileOwner as
+// owning every file.
+type ownerFs struct {
+	pathfs.FileSystem
+	fileOwner fuse.Owner
+	chown     fuse.Owner
+}
+
+func (fs *ownerFs) GetAttr(name string, context *fuse.Context) (*fuse.Attr, fuse.Status) {
+	return &fuse.Attr{Mode: fuse.S_IFREG | 0644, Owner: fs.fileOwner}, fuse.OK
+}
+
+func (fs *ownerFs) Chown(name string, uid uint32, gid uint32, context *fuse.Context) fuse.Status {
+	fs.chown = fuse.Owner{Uid: uid, Gid: gid}
+	return fuse.OK
+}
+
+func TestOwnerMap(t *testing.T) {
+	backend := &ownerFs{FileSystem: pathfs.NewDefaultFileSystem()}
+	plain, stop := dialFs(t, server.New(backend))
+	defer stop()
+	fs := NewWithOptions(plain.client, Options{Owners: &OwnerMap{
+		UIDs: map[uint32]uint32{5001: 1000},
+		GIDs: map[uint32]uint32{500: 100},
+	}})
+
+	for file, want := range map[fuse.Owner]fuse.Owner{
+		{Uid: 5001, Gid: 500}: {Uid: 1000, Gid: 100},
+		{Uid: 7, Gid: 500}:    {Uid: 7, Gid: 100},
+	} {
+		backend.fileOwner = file
+		attr, code := fs.GetAttr("file", &fuse.Context{})
+		if code != fuse.OK {
+			t.Fatal(code)
+		}
+		if attr.Owner != want {
+			t.Errorf("file owned by %v on the server is owned by %v locally, want %v", file, attr.Owner, want)
+		}
+	}
+
+	if code := fs.Chown("file", 1000, ^uint32(0), &fuse.Context{}); code != fuse.OK {
+		t.Fatal(code)
+	}
+	if want := (fuse.Owner{Uid: 5001, Gid: ^uint32(0)}); backend.chown != want {
+		t.Errorf("chown to 1000:-1 locally is %v on the server, want %v", backend.chown, want)
+	}
+
+	mine := NewWithOptions(fs.client, Options{Owners: &OwnerMap{Mine: true}})
+	attr, code := mine.GetAttr("file", &fuse.Context{})
+	if code != fuse.OK {
+		t.Fatal(code)
+	}
+	me := fuse.Owner{Uid: uint32(os.Getuid()), Gid: uint32(os.Getgid())}
+	if attr.Owner != me {
+		t.Errorf("file is owned by %v, want %v", attr.Owner, me)
+	}
+	backend.chown = fuse.Owner{}
+	if code := mine.Chown("file", me.Uid, me.Gid, &fuse.Context{}); code != fuse.OK {
+		t.Fatal(code)
+	}
+	if want := (fuse.Owner{Uid: ^uint32(0), Gid: ^uint32(0)}); backend.chown != want {
+		t.Errorf("chown to the mounting user is %v on the server, want %v", backend.chown, want)
+	}
+	if code := mine.Chown("file", me.Uid+1, ^uint32(0), &fuse.Context{}); code != fuse.EPERM {
+		t.Errorf("chown to another user: got %v, want EPERM", code)
+	}
+}
@@ -1,11 +1,14 @@
 package grpcfs
 
 import (
+	"bytes"
+	"fmt"
 	"io/ioutil"
 	"net"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/LK4D4/grfuse/pb"
 	"github.com/LK4D4/grfuse/server"
@@ -19,18 +22,29 @@ type loopbackServer struct {
 }
 
 func startLoopbackServer(root string) (*loopbackServer, error) {
+	return startLoopbackServerWithOptions(root, server.Options{})
+}
+
+func startLoopbackServerWithOptions(root string, opts server.Options) (*loopbackServer, error) {
+	return startGrpcServer(pathfs.NewLoopbackFileSystem(root), opts)
+}
+
+func startGrpcServer(fs pathfs.FileSystem, opts server.Options) (*loopbackServer, error) {
 	l, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
 		return nil, err
 	}
+	return serveGrpc(l, fs, opts), nil
+}
+
+func serveGrpc(l net.Listener, fs pathfs.FileSystem, opts server.Options) *loopbackServer {
 	s := grpc.NewServer()
-	nfs := pathfs.NewLoopbackFileSystem(root)
-	pb.RegisterPathFSServer(s, server.New(nfs))
+	pb.RegisterPathFSServer(s, server.NewWithOptions(fs, opts))
 	go s.Serve(l)
 	return &loopbackServer{
 		Server: s,
 		Addr:   l.Addr().String(),
-	}, nil
+	}
 }
 
 type roots struct {
@@ -51,7 +65,173 @@ func testMkdir(t *testing.T, r roots) {
 	}
 }
 
-func TestPathOps(t *testing.T) {
+func testReadWrite(t *testing.T, r roots) {
+	srvPath := filepath.Join(r.srv, "readwrite")
+	if err := ioutil.WriteFile(srvPath, []byte("hello world"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(filepath.Join(r.cli, "readwrite"), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte("grpc!"), 6); err != nil {
+		f.Close()
+		t.Fatal(err)
+	}
+	buf := make([]byte, 11)
+	if _, err := f.ReadAt(buf, 0); err != nil {
+		f.Close()
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "hello grpc!" {
+		t.Fatalf("expected to read \"hello grpc!\", got %q", buf)
+	}
+	data, err := ioutil.ReadFile(srvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello grpc!" {
+		t.Fatalf("expected server file to contain \"hello grpc!\", got %q", data)
+	}
+}
+
+func testCreate(t *testing.T, r roots) {
+	if err := ioutil.WriteFile(filepath.Join(r.cli, "create"), []byte("created"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(filepath.Join(r.srv, "create"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("expected mode 0600, got %v", fi.Mode().Perm())
+	}
+	data, err := ioutil.ReadFile(filepath.Join(r.srv, "create"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "created" {
+		t.Fatalf("expected server file to contain \"created\", got %q", data)
+	}
+}
+
+func testLargeRead(t *testing.T, r roots) {
+	// Larger than the default gRPC message size limit.
+	data := bytes.Repeat([]byte("0123456789abcdef"), 640<<10)
+	if err := ioutil.WriteFile(filepath.Join(r.srv, "large"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(filepath.Join(r.cli, "large"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("read %d bytes which differ from the %d bytes written", len(got), len(data))
+	}
+}
+
+func testLargeWrite(t *testing.T, r roots) {
+	data := bytes.Repeat([]byte("fedcba9876543210"), 640<<10)
+	if err := ioutil.WriteFile(filepath.Join(r.cli, "large-write"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(filepath.Join(r.srv, "large-write"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("server has %d bytes which differ from the %d bytes written", len(got), len(data))
+	}
+}
+
+func testNegativeLookup(t *testing.T, r roots) {
+	name := filepath.Join(r.cli, "negative")
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Fatalf("expected not exist error, got %v", err)
+	}
+	if err := os.Mkdir(name, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(name); err != nil {
+		t.Fatalf("directory isn't visible after mkdir: %v", err)
+	}
+}
+
+func testReadDir(t *testing.T, r roots) {
+	dir := filepath.Join(r.srv, "readdir")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"a", "b", "c"} {
+		data := bytes.Repeat([]byte{'x'}, i)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fis, err := ioutil.ReadDir(filepath.Join(r.cli, "readdir"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(fis))
+	}
+	for i, fi := range fis {
+		if fi.Size() != int64(i) {
+			t.Fatalf("expected %s to have size %d, got %d", fi.Name(), i, fi.Size())
+		}
+	}
+}
+
+// testLargeDir lists a directory which is sent in several batches.
+func testLargeDir(t *testing.T, r roots) {
+	const n = 2500
+	dir := filepath.Join(r.srv, "largedir")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if err := ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("f%05d", i)), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fis, err := ioutil.ReadDir(filepath.Join(r.cli, "largedir"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != n {
+		t.Fatalf("expected %d entries, got %d", n, len(fis))
+	}
+	for i, fi := range fis {
+		if expected := fmt.Sprintf("f%05d", i); fi.Name() != expected {
+			t.Fatalf("expected entry %s, got %s", expected, fi.Name())
+		}
+	}
+}
+
+func testChmod(t *testing.T, r roots) {
+	name := filepath.Join(r.cli, "chmod")
+	if err := ioutil.WriteFile(name, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(name); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(name, 0600); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("expected mode 0600 after chmod, got %v", fi.Mode().Perm())
+	}
+}
+
+func testPathOps(t *testing.T, opts Options) {
 	tmpSrv, err := ioutil.TempDir("", "fuse-server-")
 	if err != nil {
 		t.Fatal(err)
@@ -67,7 +247,7 @@ func TestPathOps(t *testing.T) {
 		t.Fatal(err)
 	}
 	defer s.Stop()
-	cliFs, err := startFs(tmpCli, s.Addr)
+	cliFs, err := startFsWithOptions(tmpCli, s.Addr, opts)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -77,4 +257,23 @@ func TestPathOps(t *testing.T) {
 		cli: tmpCli,
 	}
 	testMkdir(t, r)
+	testReadWrite(t, r)
+	testCreate(t, r)
+	testLargeRead(t, r)
+	testLargeWrite(t, r)
+	testChmod(t, r)
+	testNegativeLookup(t, r)
+	testReadDir(t, r)
+	testLargeDir(t, r)
+}
+
+func TestPathOps(t *testing.T) {
+	testPathOps(t, Options{})
+}
+
+func TestCachedPathOps(t *testing.T) {
+	testPathOps(t, Options{
+		AttrTimeout:     time.Minute,
+		NegativeTimeout: time.Minute,
+	})
 }
@@ -0,0 +1,31 @@
+package grpcfs
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/LK4D4/grfuse/server"
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+)
+
+// TestReadOnly checks that clients learn about read-only exports. The
+// server package tests what is rejected.
+func TestReadOnly(t *testing.T) {
+	srv := server.NewWithOptions(&HelloFs{FileSystem: pathfs.NewDefaultFileSystem()}, server.Options{ReadOnly: true})
+	fs, stop := dialFs(t, srv)
+	defer stop()
+
+	if !fs.ReadOnly() {
+		t.Fatal("server doesn't advertise read-only export")
+	}
+	if code := fs.Mkdir("dir", 0755, nil); code != fuse.Status(syscall.EROFS) {
+		t.Errorf("Mkdir: got %v, want EROFS", code)
+	}
+
+	rw, stopRw := dialFs(t, server.New(&HelloFs{FileSystem: pathfs.NewDefaultFileSystem()}))
+	defer stopRw()
+	if rw.ReadOnly() {
+		t.Error("writable server advertises read-only export")
+	}
+}
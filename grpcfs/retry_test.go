@@ -0,0 +1,301 @@
+package grpcfs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/LK4D4/grfuse/pb"
+	"github.com/LK4D4/grfuse/server"
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// unusedAddr returns an address nothing listens on.
+func unusedAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+func TestSoftMount(t *testing.T) {
+	tmpCli, err := ioutil.TempDir("", "fuse-client-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpCli)
+	cliFs, err := startFsWithOptions(tmpCli, unusedAddr(t), Options{
+		Mount:   SoftMount,
+		Retries: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cliFs.Close()
+
+	_, err = os.Stat(filepath.Join(tmpCli, "file"))
+	if pe, ok := err.(*os.PathError); !ok || pe.Err != syscall.EIO {
+		t.Fatalf("expected EIO, got %v", err)
+	}
+}
+
+func TestHardMount(t *testing.T) {
+	tmpSrv, err := ioutil.TempDir("", "fuse-server-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpSrv)
+	tmpCli, err := ioutil.TempDir("", "fuse-client-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpCli)
+	if err := ioutil.WriteFile(filepath.Join(tmpSrv, "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	addr := unusedAddr(t)
+	cliFs, err := startFsWithOptions(tmpCli, addr, Options{
+		Mount: HardMount,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cliFs.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := os.Stat(filepath.Join(tmpCli, "file"))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("stat returned before the server was started: %v", err)
+	case <-time.After(500 * time.Millisecond):
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := serveGrpc(l, pathfs.NewLoopbackFileSystem(tmpSrv), server.Options{})
+	defer s.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("stat didn't return after the server was started")
+	}
+}
+
+// restart stops s and serves root on the same address again.
+func restart(t *testing.T, s *loopbackServer, root string) *loopbackServer {
+	s.Stop()
+	var (
+		l   net.Listener
+		err error
+	)
+	// The old listener might not be closed right away.
+	for i := 0; i < 50; i++ {
+		if l, err = net.Listen("tcp", s.Addr); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serveGrpc(l, pathfs.NewLoopbackFileSystem(root), server.Options{})
+}
+
+func TestReopenAfterRestart(t *testing.T) {
+	tmpSrv, err := ioutil.TempDir("", "fuse-server-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpSrv)
+	tmpCli, err := ioutil.TempDir("", "fuse-client-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpCli)
+	// Large enough that later reads aren't served from the kernel's
+	// readahead.
+	data := make([]byte, 4<<20)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	for _, name := range []string{"kept", "removed"} {
+		if err := ioutil.WriteFile(filepath.Join(tmpSrv, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv, err := startLoopbackServer(tmpSrv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cliFs, err := startFsWithOptions(tmpCli, srv.Addr, Options{
+		Mount: HardMount,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cliFs.Close()
+
+	kept, err := os.Open(filepath.Join(tmpCli, "kept"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer kept.Close()
+	removed, err := os.Open(filepath.Join(tmpCli, "removed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer removed.Close()
+	buf := make([]byte, 4096)
+	if _, err := kept.ReadAt(buf, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	srv = restart(t, srv, tmpSrv)
+	defer srv.Stop()
+	if err := os.Remove(filepath.Join(tmpSrv, "removed")); err != nil {
+		t.Fatal(err)
+	}
+
+	off := int64(len(data) - len(buf))
+	if _, err := kept.ReadAt(buf, off); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, data[off:]) {
+		t.Fatal("read wrong data after the server restarted")
+	}
+	_, err = removed.ReadAt(buf, 0)
+	if pe, ok := err.(*os.PathError); !ok || pe.Err != syscall.ESTALE {
+		t.Fatalf("expected ESTALE, got %v", err)
+	}
+}
+
+// slowFs answers the first GetAttr and every Mkdir too late, and counts
+// the calls.
+type slowFs struct {
+	pathfs.FileSystem
+	getAttrs, mkdirs int32
+}
+
+func (fs *slowFs) GetAttr(name string, context *fuse.Context) (*fuse.Attr, fuse.Status) {
+	if atomic.AddInt32(&fs.getAttrs, 1) == 1 {
+		time.Sleep(200 * time.Millisecond)
+	}
+	return &fuse.Attr{Mode: fuse.S_IFDIR | 0755}, fuse.OK
+}
+
+func (fs *slowFs) Mkdir(name string, mode uint32, context *fuse.Context) fuse.Status {
+	atomic.AddInt32(&fs.mkdirs, 1)
+	time.Sleep(200 * time.Millisecond)
+	return fuse.OK
+}
+
+func TestRetryTimedOut(t *testing.T) {
+	backend := &slowFs{FileSystem: pathfs.NewDefaultFileSystem()}
+	plain, stop := dialFs(t, server.New(backend))
+	defer stop()
+	fs := NewWithOptions(plain.client, Options{
+		Mount:           HardMount,
+		MetadataTimeout: 50 * time.Millisecond,
+	})
+
+	if _, code := fs.GetAttr("dir", &fuse.Context{}); code != fuse.OK {
+		t.Fatalf("GetAttr: %v", code)
+	}
+	if n := atomic.LoadInt32(&backend.getAttrs); n != 2 {
+		t.Fatalf("GetAttr reached the server %d times, want 2", n)
+	}
+	if code := fs.Mkdir("dir", 0755, &fuse.Context{}); code != fuse.Status(syscall.ETIMEDOUT) {
+		t.Fatalf("Mkdir: got %v, want ETIMEDOUT", code)
+	}
+	if n := atomic.LoadInt32(&backend.mkdirs); n != 1 {
+		t.Fatalf("Mkdir reached the server %d times, want 1", n)
+	}
+}
+
+// unavailableServer fails every Mkdir with Unavailable after it reached
+// it, as a proxy losing its backend would, and counts them.
+type unavailableServer struct {
+	pb.PathFSServer
+	mkdirs int32
+}
+
+func (s *unavailableServer) Mkdir(ctx context.Context, r *pb.MkdirRequest) (*pb.MkdirResponse, error) {
+	atomic.AddInt32(&s.mkdirs, 1)
+	return nil, grpc.Errorf(codes.Unavailable, "backend went away")
+}
+
+func TestRetryUnavailable(t *testing.T) {
+	backend := &slowFs{FileSystem: pathfs.NewDefaultFileSystem()}
+	srv := &unavailableServer{PathFSServer: server.New(backend)}
+	plain, stop := dialFs(t, srv)
+	defer stop()
+	fs := NewWithOptions(plain.client, Options{Mount: HardMount})
+
+	// Mkdir might have been run, so it isn't repeated.
+	if code := fs.Mkdir("dir", 0755, &fuse.Context{}); code != fuse.EIO {
+		t.Fatalf("Mkdir: got %v, want EIO", code)
+	}
+	if n := atomic.LoadInt32(&srv.mkdirs); n != 1 {
+		t.Fatalf("Mkdir reached the server %d times, want 1", n)
+	}
+}
+
+func TestRetryUnsent(t *testing.T) {
+	addr := unusedAddr(t)
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fs := NewWithOptions(pb.NewPathFSClient(conn), Options{Mount: HardMount})
+
+	done := make(chan fuse.Status, 1)
+	go func() { done <- fs.Mkdir("dir", 0755, &fuse.Context{}) }()
+	select {
+	case code := <-done:
+		t.Fatalf("Mkdir returned %v before the server was started", code)
+	case <-time.After(500 * time.Millisecond):
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := &slowFs{FileSystem: pathfs.NewDefaultFileSystem()}
+	s := grpc.NewServer()
+	pb.RegisterPathFSServer(s, server.New(backend))
+	go s.Serve(l)
+	defer s.Stop()
+	// Mkdir never reached the server while it was down, so it is
+	// repeated until it does.
+	select {
+	case code := <-done:
+		if code != fuse.OK {
+			t.Fatalf("Mkdir: %v", code)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("Mkdir didn't return after the server was started")
+	}
+	if n := atomic.LoadInt32(&backend.mkdirs); n != 1 {
+		t.Fatalf("Mkdir reached the server %d times, want 1", n)
+	}
+}
@@ -0,0 +1,37 @@
+package grpcfs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// signalPending reports whether the thread with the given id has a signal
+// pending which it doesn't block. The kernel leaves such signals pending
+// while the thread waits for a FUSE request.
+func signalPending(tid uint32) bool {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/status", tid))
+	if err != nil {
+		// The process is gone, nobody waits for the call anymore.
+		return os.IsNotExist(err)
+	}
+	defer f.Close()
+	var pending, blocked uint64
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[0] {
+		case "SigPnd:", "ShdPnd:":
+			v, _ := strconv.ParseUint(fields[1], 16, 64)
+			pending |= v
+		case "SigBlk:":
+			blocked, _ = strconv.ParseUint(fields[1], 16, 64)
+		}
+	}
+	return pending&^blocked != 0
+}
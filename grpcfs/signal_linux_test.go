@@ -0,0 +1,28 @@
+package grpcfs
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/LK4D4/grfuse/server"
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+)
+
+func TestIntrCancelsCall(t *testing.T) {
+	backend := &hangingFs{
+		FileSystem: pathfs.NewDefaultFileSystem(),
+		unblock:    make(chan struct{}),
+	}
+	plain, stop := dialFs(t, server.New(backend))
+	defer stop()
+	defer close(backend.unblock)
+	fs := NewWithOptions(plain.client, Options{Intr: true})
+
+	// Above the largest pid, so the process counts as gone, which
+	// interrupts the call like a signal.
+	ctx := &fuse.Context{Pid: 1<<22 + 1}
+	if _, code := fs.GetAttr("hang", ctx); code != fuse.Status(syscall.EINTR) {
+		t.Fatalf("GetAttr: got %v, want EINTR", code)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package grpcfs
+
+// signalPending always reports false, as there is no way to tell.
+func signalPending(tid uint32) bool {
+	return false
+}
@@ -0,0 +1,69 @@
+package grpcfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/LK4D4/grfuse/server"
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+)
+
+// hangingFs never answers GetAttr for "hang" until unblocked.
+type hangingFs struct {
+	pathfs.FileSystem
+	unblock chan struct{}
+}
+
+func (fs *hangingFs) GetAttr(name string, ctx *fuse.Context) (*fuse.Attr, fuse.Status) {
+	if name == "hang" {
+		<-fs.unblock
+	}
+	return fs.FileSystem.GetAttr(name, ctx)
+}
+
+func TestMetadataTimeout(t *testing.T) {
+	tmpSrv, err := ioutil.TempDir("", "fuse-server-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpSrv)
+	tmpCli, err := ioutil.TempDir("", "fuse-client-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpCli)
+	hfs := &hangingFs{
+		FileSystem: pathfs.NewLoopbackFileSystem(tmpSrv),
+		unblock:    make(chan struct{}),
+	}
+	s, err := startGrpcServer(hfs, server.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	defer close(hfs.unblock)
+	cliFs, err := startFsWithOptions(tmpCli, s.Addr, Options{
+		MetadataTimeout: 200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cliFs.Close()
+
+	if err := ioutil.WriteFile(filepath.Join(tmpSrv, "hang"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	_, err = os.Stat(filepath.Join(tmpCli, "hang"))
+	if pe, ok := err.(*os.PathError); !ok || pe.Err != syscall.ETIMEDOUT {
+		t.Fatalf("expected ETIMEDOUT, got %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("stat took %v", d)
+	}
+}
@@ -0,0 +1,199 @@
+package grpcfs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LK4D4/grfuse/pb"
+	"github.com/LK4D4/grfuse/server"
+	"github.com/LK4D4/grfuse/tlsutil"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// writeCert writes a certificate for name signed by parent (self-signed if
+// nil) and its key to dir, and returns both.
+func writeCert(t *testing.T, dir, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kder, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder})
+	if err := ioutil.WriteFile(filepath.Join(dir, name+".pem"), certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, name+"-key.pem"), keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// identityServer records the client certificate's name on Hello.
+type identityServer struct {
+	pb.PathFSServer
+	name chan string
+}
+
+func (s identityServer) Hello(ctx context.Context, r *pb.HelloRequest) (*pb.HelloResponse, error) {
+	if cert, ok := tlsutil.PeerCertificate(ctx); ok {
+		s.name <- cert.Subject.CommonName
+	} else {
+		s.name <- ""
+	}
+	return s.PathFSServer.Hello(ctx, r)
+}
+
+// tlsFiles writes a CA and certificates for the server "localhost" and
+// the client "alice" to a temporary directory.
+func tlsFiles(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "grfuse-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, caKey := writeCert(t, dir, "ca", nil, nil)
+	writeCert(t, dir, "localhost", ca, caKey)
+	writeCert(t, dir, "alice", ca, caKey)
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+// serveTLS serves srv with the certificates in dir, requiring clients to
+// present one.
+func serveTLS(t *testing.T, dir string, srv pb.PathFSServer, opts ...grpc.ServerOption) (string, func()) {
+	sopt, err := tlsutil.ServerOption(tlsutil.Config{
+		CertFile: filepath.Join(dir, "localhost.pem"),
+		KeyFile:  filepath.Join(dir, "localhost-key.pem"),
+		CAFile:   filepath.Join(dir, "ca.pem"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer(append(opts, sopt)...)
+	pb.RegisterPathFSServer(s, srv)
+	go s.Serve(l)
+	return l.Addr().String(), s.Stop
+}
+
+// dialTLS connects to a server started by serveTLS, as alice unless
+// anonymous.
+func dialTLS(t *testing.T, dir, addr string, anonymous bool, opts ...grpc.DialOption) (pb.PathFSClient, func()) {
+	cfg := tlsutil.Config{
+		CAFile:     filepath.Join(dir, "ca.pem"),
+		ServerName: "localhost",
+	}
+	if !anonymous {
+		cfg.CertFile = filepath.Join(dir, "alice.pem")
+		cfg.KeyFile = filepath.Join(dir, "alice-key.pem")
+	}
+	opt, err := tlsutil.DialOption(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := grpc.Dial(addr, append(opts, opt)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pb.NewPathFSClient(conn), func() { conn.Close() }
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir, cleanup := tlsFiles(t)
+	defer cleanup()
+	srv := identityServer{
+		PathFSServer: server.New(&HelloFs{FileSystem: pathfs.NewDefaultFileSystem()}),
+		name:         make(chan string, 1),
+	}
+	addr, stop := serveTLS(t, dir, srv)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client, closeConn := dialTLS(t, dir, addr, false)
+	defer closeConn()
+	if _, err := client.Hello(ctx, &pb.HelloRequest{Version: protocolVersion}); err != nil {
+		t.Fatal(err)
+	}
+	if name := <-srv.name; name != "alice" {
+		t.Fatalf("server sees client as %q, want alice", name)
+	}
+
+	anon, closeAnon := dialTLS(t, dir, addr, true)
+	defer closeAnon()
+	if _, err := anon.Hello(ctx, &pb.HelloRequest{Version: protocolVersion}); err == nil {
+		t.Fatal("server accepted a client without a certificate")
+	}
+}
+
+func TestServerNameVerified(t *testing.T) {
+	dir, cleanup := tlsFiles(t)
+	defer cleanup()
+	addr, stop := serveTLS(t, dir, server.New(&HelloFs{FileSystem: pathfs.NewDefaultFileSystem()}))
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The certificate is for localhost, neither for the address dialed
+	// nor for the name given.
+	for _, name := range []string{"", "example.com"} {
+		opt, err := tlsutil.DialOption(tlsutil.Config{
+			CertFile:   filepath.Join(dir, "alice.pem"),
+			KeyFile:    filepath.Join(dir, "alice-key.pem"),
+			CAFile:     filepath.Join(dir, "ca.pem"),
+			ServerName: name,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, err := grpc.Dial(addr, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = pb.NewPathFSClient(conn).Hello(ctx, &pb.HelloRequest{Version: protocolVersion})
+		conn.Close()
+		if err == nil {
+			t.Fatalf("server accepted as %q when dialed at %s", name, addr)
+		}
+	}
+}
@@ -19,13 +19,17 @@ func (c *fuseClient) Close() {
 }
 
 func startFs(root, address string) (*fuseClient, error) {
+	return startFsWithOptions(root, address, Options{})
+}
+
+func startFsWithOptions(root, address string, opts Options) (*fuseClient, error) {
 	dialOpts := []grpc.DialOption{grpc.WithInsecure()}
 	conn, err := grpc.Dial(address, dialOpts...)
 	if err != nil {
 		return nil, err
 	}
 	cli := pb.NewPathFSClient(conn)
-	fs := New(cli)
+	fs := NewWithOptions(cli, opts)
 	nfs := pathfs.NewPathNodeFs(fs, nil)
 	server, _, err := nodefs.MountRoot(root, nfs.Root(), nil)
 	if err != nil {
@@ -0,0 +1,81 @@
+package grpcfs
+
+import (
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/LK4D4/grfuse/pb"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+	"golang.org/x/net/context"
+)
+
+const (
+	// minWatchBackoff and maxWatchBackoff bound the delay between
+	// attempts to restart a broken Watch stream.
+	minWatchBackoff = 100 * time.Millisecond
+	maxWatchBackoff = 30 * time.Second
+)
+
+// watchChanges applies the changes sent by the server until ctx is done.
+// Broken streams are restarted; the server starts every stream with a
+// Rescan, so changes missed in between are covered.
+func (fs *GrpcFs) watchChanges(ctx context.Context, nodeFs *pathfs.PathNodeFs) {
+	if !fs.has(pb.Feature_WatchChanges) {
+		log.Printf("Server doesn't send changes, relying on cache timeouts")
+		return
+	}
+	backoff := minWatchBackoff
+	for {
+		stream, err := fs.client.Watch(fs.outgoing(ctx, ""), &pb.WatchRequest{})
+		for err == nil {
+			var e *pb.WatchEvent
+			if e, err = stream.Recv(); err == nil {
+				backoff = minWatchBackoff
+				fs.applyChange(nodeFs, e)
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Error watching for changes, retrying in %v: %v", backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		if backoff *= 2; backoff > maxWatchBackoff {
+			backoff = maxWatchBackoff
+		}
+	}
+}
+
+// applyChange drops everything cached about the files affected by e. The
+// kernel reports ENOENT for names it doesn't know, which is ignored.
+func (fs *GrpcFs) applyChange(nodeFs *pathfs.PathNodeFs, e *pb.WatchEvent) {
+	switch e.Op {
+	case pb.WatchOp_Create, pb.WatchOp_Delete:
+		fs.attrs.invalidate(e.Name)
+		entryNotify(nodeFs, e.Name)
+	case pb.WatchOp_Modify:
+		fs.attrs.invalidate(e.Name)
+		nodeFs.FileNotify(e.Name, 0, 0)
+	case pb.WatchOp_Rename:
+		fs.attrs.invalidateTree(e.Name)
+		fs.attrs.invalidateTree(e.NewName)
+		entryNotify(nodeFs, e.Name)
+		entryNotify(nodeFs, e.NewName)
+	default:
+		// The kernel can only be told about single files, entries
+		// below the root expire on their own.
+		fs.attrs.invalidateTree("")
+		nodeFs.Notify("")
+	}
+}
+
+// entryNotify tells the kernel to drop its directory entry for name. The
+// root is "" for pathfs, not ".".
+func entryNotify(nodeFs *pathfs.PathNodeFs, name string) {
+	dir, base := filepath.Split(name)
+	nodeFs.EntryNotify(filepath.Clean("/" + dir)[1:], base)
+}
@@ -0,0 +1,179 @@
+package grpcfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LK4D4/grfuse/server"
+)
+
+// waitFor polls f until it returns true or the timeout expires.
+func waitFor(timeout time.Duration, f func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if f() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return f()
+}
+
+// TestWatch checks that changes made through one client are seen by
+// another one which caches attributes for much longer than the test runs.
+func TestWatch(t *testing.T) {
+	tmpSrv, err := ioutil.TempDir("", "fuse-server-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpSrv)
+	tmpWatcher, err := ioutil.TempDir("", "fuse-client-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpWatcher)
+	tmpWriter, err := ioutil.TempDir("", "fuse-client-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpWriter)
+	s, err := startLoopbackServer(tmpSrv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	watcher, err := startFsWithOptions(tmpWatcher, s.Addr, Options{
+		AttrTimeout:     time.Hour,
+		NegativeTimeout: time.Hour,
+		Watch:           true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+	writer, err := startFs(tmpWriter, s.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	name := filepath.Join(tmpWatcher, "watched")
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Fatalf("expected ENOENT, got %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpWriter, "watched"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !waitFor(5*time.Second, func() bool {
+		fi, err := os.Stat(name)
+		return err == nil && fi.Size() == 5
+	}) {
+		t.Fatal("creation of watched was not noticed")
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpWriter, "watched"), []byte("hello, world"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !waitFor(5*time.Second, func() bool {
+		data, err := ioutil.ReadFile(name)
+		return err == nil && string(data) == "hello, world"
+	}) {
+		t.Fatal("modification of watched was not noticed")
+	}
+	if err := os.Remove(filepath.Join(tmpWriter, "watched")); err != nil {
+		t.Fatal(err)
+	}
+	if !waitFor(5*time.Second, func() bool {
+		_, err := os.Stat(name)
+		return os.IsNotExist(err)
+	}) {
+		t.Fatal("removal of watched was not noticed")
+	}
+}
+
+// TestInotifyWatch checks that changes made directly to the exported
+// directory are seen by a client.
+func TestInotifyWatch(t *testing.T) {
+	tmpSrv, err := ioutil.TempDir("", "fuse-server-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpSrv)
+	tmpCli, err := ioutil.TempDir("", "fuse-client-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpCli)
+	changes, err := server.NewInotifySource(tmpSrv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer changes.Close()
+	s, err := startLoopbackServerWithOptions(tmpSrv, server.Options{Changes: changes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	cliFs, err := startFsWithOptions(tmpCli, s.Addr, Options{
+		AttrTimeout:     time.Hour,
+		NegativeTimeout: time.Hour,
+		Watch:           true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cliFs.Close()
+
+	name := filepath.Join(tmpCli, "dir", "file")
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Fatalf("expected ENOENT, got %v", err)
+	}
+	// The file is created in a new directory, which has to be
+	// watched first.
+	if err := os.Mkdir(filepath.Join(tmpSrv, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpSrv, "dir", "file"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !waitFor(5*time.Second, func() bool {
+		fi, err := os.Stat(name)
+		return err == nil && fi.Size() == 5
+	}) {
+		t.Fatal("creation of dir/file was not noticed")
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpSrv, "dir", "file"), []byte("hello, world"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !waitFor(5*time.Second, func() bool {
+		fi, err := os.Stat(name)
+		return err == nil && fi.Size() == 12
+	}) {
+		t.Fatal("modification of dir/file was not noticed")
+	}
+	if err := os.Rename(filepath.Join(tmpSrv, "dir"), filepath.Join(tmpSrv, "renamed")); err != nil {
+		t.Fatal(err)
+	}
+	if !waitFor(5*time.Second, func() bool {
+		_, err := os.Stat(name)
+		return os.IsNotExist(err)
+	}) {
+		t.Fatal("rename of dir was not noticed")
+	}
+	// Changes in the renamed directory are still reported.
+	renamed := filepath.Join(tmpCli, "renamed", "file")
+	if _, err := os.Stat(renamed); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(tmpSrv, "renamed", "file")); err != nil {
+		t.Fatal(err)
+	}
+	if !waitFor(5*time.Second, func() bool {
+		_, err := os.Stat(renamed)
+		return os.IsNotExist(err)
+	}) {
+		t.Fatal("removal of renamed/file was not noticed")
+	}
+}
@@ -0,0 +1,260 @@
+// Package metrics collects Prometheus metrics of grfuse calls. They are
+// recorded by gRPC interceptors, so neither the server nor the client
+// package depends on Prometheus. For a server:
+//
+//	m := metrics.NewServerMetrics()
+//	s := grpc.NewServer(
+//		grpc.UnaryInterceptor(m.UnaryServerInterceptor()),
+//		grpc.StreamInterceptor(m.StreamServerInterceptor()),
+//	)
+//	pb.RegisterPathFSServer(s, server.New(fs))
+//	http.Handle("/metrics", m.Handler())
+//
+// Clients pass the client interceptors to grpc.Dial instead. Metrics can
+// also be registered with another registry, as they are a
+// prometheus.Collector.
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/LK4D4/grfuse/pb"
+	"github.com/gogo/protobuf/proto"
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// Metrics are the metrics of the calls made or served through its
+// interceptors, labeled by the method called.
+type Metrics struct {
+	calls    *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	bytes    *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+}
+
+// NewServerMetrics returns metrics named grfuse_server_*.
+func NewServerMetrics() *Metrics {
+	return newMetrics("server")
+}
+
+// NewClientMetrics returns metrics named grfuse_client_*.
+func NewClientMetrics() *Metrics {
+	return newMetrics("client")
+}
+
+func newMetrics(subsystem string) *Metrics {
+	return &Metrics{
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "grfuse",
+			Subsystem: subsystem,
+			Name:      "calls_total",
+			Help:      "Number of finished calls by method and gRPC code.",
+		}, []string{"method", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "grfuse",
+			Subsystem: subsystem,
+			Name:      "call_duration_seconds",
+			Help:      "Duration of calls by method, for streams until they end.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}, []string{"method"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "grfuse",
+			Subsystem: subsystem,
+			Name:      "bytes_total",
+			Help:      "Size of the messages sent and received by method.",
+		}, []string{"method", "direction"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "grfuse",
+			Subsystem: subsystem,
+			Name:      "fuse_errors_total",
+			Help:      "Number of responses with a failure status by method and status.",
+		}, []string{"method", "status"}),
+	}
+}
+
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.calls.Describe(ch)
+	m.duration.Describe(ch)
+	m.bytes.Describe(ch)
+	m.errors.Describe(ch)
+}
+
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.calls.Collect(ch)
+	m.duration.Collect(ch)
+	m.bytes.Collect(ch)
+	m.errors.Collect(ch)
+}
+
+// Handler returns a handler serving m in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	r := prometheus.NewRegistry()
+	r.MustRegister(m)
+	return promhttp.HandlerFor(r, promhttp.HandlerOpts{})
+}
+
+// methodName returns the name of the method called, e.g. "Read" for
+// "/pb.PathFS/Read".
+func methodName(fullMethod string) string {
+	return path.Base(fullMethod)
+}
+
+func (m *Metrics) sent(method string, msg interface{}) {
+	m.message(method, "sent", msg)
+}
+
+func (m *Metrics) received(method string, msg interface{}) {
+	m.message(method, "received", msg)
+}
+
+func (m *Metrics) message(method, direction string, msg interface{}) {
+	if pm, ok := msg.(proto.Message); ok {
+		m.bytes.WithLabelValues(method, direction).Add(float64(proto.Size(pm)))
+	}
+	if r, ok := msg.(interface {
+		GetStatus() *pb.Status
+	}); ok {
+		if st := r.GetStatus(); st != nil && st.Code != fuse.OK {
+			m.errors.WithLabelValues(method, st.Code.String()).Inc()
+		}
+	}
+}
+
+func (m *Metrics) done(method string, start time.Time, err error) {
+	m.calls.WithLabelValues(method, grpc.Code(err).String()).Inc()
+	m.duration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		method := methodName(info.FullMethod)
+		start := time.Now()
+		m.received(method, req)
+		resp, err := handler(ctx, req)
+		if err == nil {
+			m.sent(method, resp)
+		}
+		m.done(method, start, err)
+		return resp, err
+	}
+}
+
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		method := methodName(info.FullMethod)
+		start := time.Now()
+		err := handler(srv, &serverStream{
+			ServerStream: ss,
+			m:            m,
+			method:       method,
+		})
+		m.done(method, start, err)
+		return err
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	m      *Metrics
+	method string
+}
+
+func (s *serverStream) SendMsg(msg interface{}) error {
+	err := s.ServerStream.SendMsg(msg)
+	if err == nil {
+		s.m.sent(s.method, msg)
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(msg interface{}) error {
+	err := s.ServerStream.RecvMsg(msg)
+	if err == nil {
+		s.m.received(s.method, msg)
+	}
+	return err
+}
+
+func (m *Metrics) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, fullMethod string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		method := methodName(fullMethod)
+		start := time.Now()
+		m.sent(method, req)
+		err := invoker(ctx, fullMethod, req, reply, cc, opts...)
+		if err == nil {
+			m.received(method, reply)
+		}
+		m.done(method, start, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor records streams once they end. Streams which
+// are abandoned by canceling their context aren't counted as calls.
+func (m *Metrics) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, fullMethod string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		method := methodName(fullMethod)
+		start := time.Now()
+		cs, err := streamer(ctx, desc, cc, fullMethod, opts...)
+		if err != nil {
+			m.done(method, start, err)
+			return nil, err
+		}
+		return &clientStream{
+			ClientStream:  cs,
+			m:             m,
+			method:        method,
+			start:         start,
+			serverStreams: desc.ServerStreams,
+		}, nil
+	}
+}
+
+type clientStream struct {
+	grpc.ClientStream
+	m             *Metrics
+	method        string
+	start         time.Time
+	serverStreams bool
+	once          sync.Once
+}
+
+func (s *clientStream) SendMsg(msg interface{}) error {
+	err := s.ClientStream.SendMsg(msg)
+	if err == nil {
+		s.m.sent(s.method, msg)
+	}
+	return err
+}
+
+// RecvMsg ends the call at the end of the stream, or after the single
+// response of a call which only streams requests.
+func (s *clientStream) RecvMsg(msg interface{}) error {
+	err := s.ClientStream.RecvMsg(msg)
+	switch {
+	case err == io.EOF:
+		s.finish(nil)
+	case err != nil:
+		s.finish(err)
+	default:
+		s.m.received(s.method, msg)
+		if !s.serverStreams {
+			s.finish(nil)
+		}
+	}
+	return err
+}
+
+func (s *clientStream) finish(err error) {
+	s.once.Do(func() {
+		s.m.done(s.method, s.start, err)
+	})
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LK4D4/grfuse/pb"
+	"github.com/hanwen/go-fuse/fuse"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// testServer fails calls on "denied" with PermissionDenied and those on
+// "missing" with ENOENT. It only implements GetAttr and OpenDirStream.
+type testServer struct {
+	pb.PathFSServer
+}
+
+func (s testServer) GetAttr(ctx context.Context, r *pb.GetAttrRequest) (*pb.GetAttrResponse, error) {
+	switch r.Name {
+	case "denied":
+		return nil, grpc.Errorf(codes.PermissionDenied, "denied")
+	case "missing":
+		return &pb.GetAttrResponse{Status: &pb.Status{Code: fuse.ENOENT}}, nil
+	}
+	return &pb.GetAttrResponse{Attr: &pb.Attr{Mode: fuse.S_IFREG | 0644}, Status: &pb.Status{}}, nil
+}
+
+func (s testServer) OpenDirStream(r *pb.OpenDirRequest, stream pb.PathFS_OpenDirStreamServer) error {
+	if r.Name == "denied" {
+		return grpc.Errorf(codes.PermissionDenied, "denied")
+	}
+	return stream.Send(&pb.OpenDirResponse{Dirs: []*pb.DirEntry{{Name: "file"}}, Status: &pb.Status{}})
+}
+
+// scrape returns the lines m serves.
+func scrape(t *testing.T, m *Metrics) map[string]bool {
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	data, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := make(map[string]bool)
+	for _, l := range strings.Split(string(data), "\n") {
+		lines[l] = true
+	}
+	return lines
+}
+
+func TestInterceptors(t *testing.T) {
+	server, client := NewServerMetrics(), NewClientMetrics()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(server.UnaryServerInterceptor()),
+		grpc.StreamInterceptor(server.StreamServerInterceptor()),
+	)
+	pb.RegisterPathFSServer(s, testServer{})
+	go s.Serve(l)
+	defer s.Stop()
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(client.UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(client.StreamClientInterceptor()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	c := pb.NewPathFSClient(conn)
+	ctx := context.Background()
+
+	for _, name := range []string{"file", "missing", "denied"} {
+		c.GetAttr(ctx, &pb.GetAttrRequest{Name: name})
+		stream, err := c.OpenDirStream(ctx, &pb.OpenDirRequest{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for err == nil {
+			_, err = stream.Recv()
+		}
+		if err != io.EOF && grpc.Code(err) != codes.PermissionDenied {
+			t.Fatalf("OpenDirStream %q: %v", name, err)
+		}
+	}
+
+	for side, m := range map[string]*Metrics{"server": server, "client": client} {
+		lines := scrape(t, m)
+		for _, want := range []string{
+			`calls_total{code="OK",method="GetAttr"} 2`,
+			`calls_total{code="PermissionDenied",method="GetAttr"} 1`,
+			`calls_total{code="OK",method="OpenDirStream"} 2`,
+			`calls_total{code="PermissionDenied",method="OpenDirStream"} 1`,
+			`call_duration_seconds_count{method="GetAttr"} 3`,
+			`call_duration_seconds_count{method="OpenDirStream"} 3`,
+			fmt.Sprintf(`fuse_errors_total{method="GetAttr",status=%q} 1`, fuse.ENOENT.String()),
+		} {
+			if want = "grfuse_" + side + "_" + want; !lines[want] {
+				t.Errorf("%s metrics lack %s", side, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,10 @@
+package pb
+
+// pathfs.pb.go is generated with protoc 3.0.0 and protoc-gen-gogo from
+// github.com/gogo/protobuf v0.4. Its grpc plugin emits server handlers
+// taking interceptors, which assert grpc.SupportPackageIsVersion4, so
+// grpc-go releases from before it can't build this package. Use the same
+// versions when regenerating it, so the output only changes with
+// pathfs.proto.
+//
+//go:generate protoc --gogo_out=plugins=grpc:. pathfs.proto
@@ -54,6 +54,20 @@ It has these top-level messages:
 	OpenResponse
 	CreateRequest
 	CreateResponse
+	ReadRequest
+	ReadResponse
+	ReadStreamRequest
+	ReadStreamResponse
+	WriteRequest
+	WriteResponse
+	WriteStreamRequest
+	WriteStreamResponse
+	FlushRequest
+	FlushResponse
+	FsyncRequest
+	FsyncResponse
+	ReleaseRequest
+	ReleaseResponse
 	DirEntry
 	OpenDirRequest
 	OpenDirResponse
@@ -64,6 +78,11 @@ It has these top-level messages:
 	StatFs
 	StatFsRequest
 	StatFsResponse
+	WatchRequest
+	WatchEvent
+	Limits
+	HelloRequest
+	HelloResponse
 */
 package pb
 
@@ -90,8 +109,80 @@ var _ = proto.Marshal
 var _ = fmt.Errorf
 var _ = math.Inf
 
+type WatchOp int32
+
+const (
+	WatchOp_Rescan WatchOp = 0
+	WatchOp_Create WatchOp = 1
+	WatchOp_Modify WatchOp = 2
+	WatchOp_Delete WatchOp = 3
+	WatchOp_Rename WatchOp = 4
+)
+
+var WatchOp_name = map[int32]string{
+	0: "Rescan",
+	1: "Create",
+	2: "Modify",
+	3: "Delete",
+	4: "Rename",
+}
+var WatchOp_value = map[string]int32{
+	"Rescan": 0,
+	"Create": 1,
+	"Modify": 2,
+	"Delete": 3,
+	"Rename": 4,
+}
+
+func (x WatchOp) String() string {
+	return proto.EnumName(WatchOp_name, int32(x))
+}
+
+type Feature int32
+
+const (
+	Feature_NoFeature    Feature = 0
+	Feature_Handles      Feature = 1
+	Feature_StaleHandles Feature = 2
+	Feature_StreamRead   Feature = 3
+	Feature_StreamWrite  Feature = 4
+	Feature_StreamDir    Feature = 5
+	Feature_DirPlus      Feature = 6
+	Feature_WatchChanges Feature = 7
+	Feature_ReadOnly     Feature = 8
+)
+
+var Feature_name = map[int32]string{
+	0: "NoFeature",
+	1: "Handles",
+	2: "StaleHandles",
+	3: "StreamRead",
+	4: "StreamWrite",
+	5: "StreamDir",
+	6: "DirPlus",
+	7: "WatchChanges",
+	8: "ReadOnly",
+}
+var Feature_value = map[string]int32{
+	"NoFeature":    0,
+	"Handles":      1,
+	"StaleHandles": 2,
+	"StreamRead":   3,
+	"StreamWrite":  4,
+	"StreamDir":    5,
+	"DirPlus":      6,
+	"WatchChanges": 7,
+	"ReadOnly":     8,
+}
+
+func (x Feature) String() string {
+	return proto.EnumName(Feature_name, int32(x))
+}
+
 type Status struct {
-	Code github_com_hanwen_go_fuse_fuse.Status `protobuf:"varint,1,opt,name=Code,proto3,casttype=github.com/hanwen/go-fuse/fuse.Status" json:"Code,omitempty"`
+	Code    github_com_hanwen_go_fuse_fuse.Status `protobuf:"varint,1,opt,name=Code,proto3,casttype=github.com/hanwen/go-fuse/fuse.Status" json:"Code,omitempty"`
+	Path    string                                `protobuf:"bytes,2,opt,name=Path,proto3" json:"Path,omitempty"`
+	Message string                                `protobuf:"bytes,3,opt,name=Message,proto3" json:"Message,omitempty"`
 }
 
 func (m *Status) Reset()      { *m = Status{} }
@@ -666,7 +757,8 @@ func (m *SetXAttrResponse) GetStatus() *Status {
 }
 
 type File struct {
-	Data []byte `protobuf:"bytes,1,opt,name=Data,proto3" json:"Data,omitempty"`
+	Data   []byte `protobuf:"bytes,1,opt,name=Data,proto3" json:"Data,omitempty"`
+	Handle uint64 `protobuf:"varint,2,opt,name=Handle,proto3" json:"Handle,omitempty"`
 }
 
 func (m *File) Reset()      { *m = File{} }
@@ -676,6 +768,7 @@ type OpenRequest struct {
 	Name    string   `protobuf:"bytes,1,opt,name=Name,proto3" json:"Name,omitempty"`
 	Flags   uint32   `protobuf:"varint,2,opt,name=Flags,proto3" json:"Flags,omitempty"`
 	Context *Context `protobuf:"bytes,3,opt,name=Context" json:"Context,omitempty"`
+	Handle  bool     `protobuf:"varint,4,opt,name=Handle,proto3" json:"Handle,omitempty"`
 }
 
 func (m *OpenRequest) Reset()      { *m = OpenRequest{} }
@@ -757,17 +850,188 @@ func (m *CreateResponse) GetContext() *Context {
 	return nil
 }
 
+type ReadRequest struct {
+	Handle uint64 `protobuf:"varint,1,opt,name=Handle,proto3" json:"Handle,omitempty"`
+	Offset int64  `protobuf:"varint,2,opt,name=Offset,proto3" json:"Offset,omitempty"`
+	Size_  uint32 `protobuf:"varint,3,opt,name=Size,proto3" json:"Size,omitempty"`
+}
+
+func (m *ReadRequest) Reset()      { *m = ReadRequest{} }
+func (*ReadRequest) ProtoMessage() {}
+
+type ReadResponse struct {
+	Data   []byte  `protobuf:"bytes,1,opt,name=Data,proto3" json:"Data,omitempty"`
+	Status *Status `protobuf:"bytes,2,opt,name=Status" json:"Status,omitempty"`
+}
+
+func (m *ReadResponse) Reset()      { *m = ReadResponse{} }
+func (*ReadResponse) ProtoMessage() {}
+
+func (m *ReadResponse) GetStatus() *Status {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+type ReadStreamRequest struct {
+	Handle    uint64 `protobuf:"varint,1,opt,name=Handle,proto3" json:"Handle,omitempty"`
+	Offset    int64  `protobuf:"varint,2,opt,name=Offset,proto3" json:"Offset,omitempty"`
+	Size_     int64  `protobuf:"varint,3,opt,name=Size,proto3" json:"Size,omitempty"`
+	ChunkSize uint32 `protobuf:"varint,4,opt,name=ChunkSize,proto3" json:"ChunkSize,omitempty"`
+}
+
+func (m *ReadStreamRequest) Reset()      { *m = ReadStreamRequest{} }
+func (*ReadStreamRequest) ProtoMessage() {}
+
+type ReadStreamResponse struct {
+	Data   []byte  `protobuf:"bytes,1,opt,name=Data,proto3" json:"Data,omitempty"`
+	Offset int64   `protobuf:"varint,2,opt,name=Offset,proto3" json:"Offset,omitempty"`
+	Status *Status `protobuf:"bytes,3,opt,name=Status" json:"Status,omitempty"`
+}
+
+func (m *ReadStreamResponse) Reset()      { *m = ReadStreamResponse{} }
+func (*ReadStreamResponse) ProtoMessage() {}
+
+func (m *ReadStreamResponse) GetStatus() *Status {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+type WriteRequest struct {
+	Handle uint64 `protobuf:"varint,1,opt,name=Handle,proto3" json:"Handle,omitempty"`
+	Offset int64  `protobuf:"varint,2,opt,name=Offset,proto3" json:"Offset,omitempty"`
+	Data   []byte `protobuf:"bytes,3,opt,name=Data,proto3" json:"Data,omitempty"`
+}
+
+func (m *WriteRequest) Reset()      { *m = WriteRequest{} }
+func (*WriteRequest) ProtoMessage() {}
+
+type WriteResponse struct {
+	Written uint32  `protobuf:"varint,1,opt,name=Written,proto3" json:"Written,omitempty"`
+	Status  *Status `protobuf:"bytes,2,opt,name=Status" json:"Status,omitempty"`
+}
+
+func (m *WriteResponse) Reset()      { *m = WriteResponse{} }
+func (*WriteResponse) ProtoMessage() {}
+
+func (m *WriteResponse) GetStatus() *Status {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+type WriteStreamRequest struct {
+	Handle uint64 `protobuf:"varint,1,opt,name=Handle,proto3" json:"Handle,omitempty"`
+	Offset int64  `protobuf:"varint,2,opt,name=Offset,proto3" json:"Offset,omitempty"`
+	Data   []byte `protobuf:"bytes,3,opt,name=Data,proto3" json:"Data,omitempty"`
+}
+
+func (m *WriteStreamRequest) Reset()      { *m = WriteStreamRequest{} }
+func (*WriteStreamRequest) ProtoMessage() {}
+
+type WriteStreamResponse struct {
+	Offset int64   `protobuf:"varint,1,opt,name=Offset,proto3" json:"Offset,omitempty"`
+	Status *Status `protobuf:"bytes,2,opt,name=Status" json:"Status,omitempty"`
+}
+
+func (m *WriteStreamResponse) Reset()      { *m = WriteStreamResponse{} }
+func (*WriteStreamResponse) ProtoMessage() {}
+
+func (m *WriteStreamResponse) GetStatus() *Status {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+type FlushRequest struct {
+	Handle uint64 `protobuf:"varint,1,opt,name=Handle,proto3" json:"Handle,omitempty"`
+}
+
+func (m *FlushRequest) Reset()      { *m = FlushRequest{} }
+func (*FlushRequest) ProtoMessage() {}
+
+type FlushResponse struct {
+	Status *Status `protobuf:"bytes,1,opt,name=Status" json:"Status,omitempty"`
+}
+
+func (m *FlushResponse) Reset()      { *m = FlushResponse{} }
+func (*FlushResponse) ProtoMessage() {}
+
+func (m *FlushResponse) GetStatus() *Status {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+type FsyncRequest struct {
+	Handle uint64 `protobuf:"varint,1,opt,name=Handle,proto3" json:"Handle,omitempty"`
+	Flags  int    `protobuf:"varint,2,opt,name=Flags,proto3,casttype=int" json:"Flags,omitempty"`
+}
+
+func (m *FsyncRequest) Reset()      { *m = FsyncRequest{} }
+func (*FsyncRequest) ProtoMessage() {}
+
+type FsyncResponse struct {
+	Status *Status `protobuf:"bytes,1,opt,name=Status" json:"Status,omitempty"`
+}
+
+func (m *FsyncResponse) Reset()      { *m = FsyncResponse{} }
+func (*FsyncResponse) ProtoMessage() {}
+
+func (m *FsyncResponse) GetStatus() *Status {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+type ReleaseRequest struct {
+	Handle uint64 `protobuf:"varint,1,opt,name=Handle,proto3" json:"Handle,omitempty"`
+}
+
+func (m *ReleaseRequest) Reset()      { *m = ReleaseRequest{} }
+func (*ReleaseRequest) ProtoMessage() {}
+
+type ReleaseResponse struct {
+	Status *Status `protobuf:"bytes,1,opt,name=Status" json:"Status,omitempty"`
+}
+
+func (m *ReleaseResponse) Reset()      { *m = ReleaseResponse{} }
+func (*ReleaseResponse) ProtoMessage() {}
+
+func (m *ReleaseResponse) GetStatus() *Status {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
 type DirEntry struct {
 	Mode uint32 `protobuf:"varint,1,opt,name=Mode,proto3" json:"Mode,omitempty"`
 	Name string `protobuf:"bytes,2,opt,name=Name,proto3" json:"Name,omitempty"`
+	Attr *Attr  `protobuf:"bytes,3,opt,name=Attr" json:"Attr,omitempty"`
 }
 
 func (m *DirEntry) Reset()      { *m = DirEntry{} }
 func (*DirEntry) ProtoMessage() {}
 
+func (m *DirEntry) GetAttr() *Attr {
+	if m != nil {
+		return m.Attr
+	}
+	return nil
+}
+
 type OpenDirRequest struct {
 	Name    string   `protobuf:"bytes,1,opt,name=Name,proto3" json:"Name,omitempty"`
 	Context *Context `protobuf:"bytes,2,opt,name=Context" json:"Context,omitempty"`
+	Plus    bool     `protobuf:"varint,3,opt,name=Plus,proto3" json:"Plus,omitempty"`
 }
 
 func (m *OpenDirRequest) Reset()      { *m = OpenDirRequest{} }
@@ -886,7 +1150,8 @@ func (m *StatFsRequest) Reset()      { *m = StatFsRequest{} }
 func (*StatFsRequest) ProtoMessage() {}
 
 type StatFsResponse struct {
-	StatFs *StatFs `protobuf:"bytes,1,opt,name=StatFs" json:"StatFs,omitempty"`
+	StatFs   *StatFs `protobuf:"bytes,1,opt,name=StatFs" json:"StatFs,omitempty"`
+	ReadOnly bool    `protobuf:"varint,2,opt,name=ReadOnly,proto3" json:"ReadOnly,omitempty"`
 }
 
 func (m *StatFsResponse) Reset()      { *m = StatFsResponse{} }
@@ -899,6 +1164,56 @@ func (m *StatFsResponse) GetStatFs() *StatFs {
 	return nil
 }
 
+type WatchRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=Name,proto3" json:"Name,omitempty"`
+}
+
+func (m *WatchRequest) Reset()      { *m = WatchRequest{} }
+func (*WatchRequest) ProtoMessage() {}
+
+type WatchEvent struct {
+	Op      WatchOp `protobuf:"varint,1,opt,name=Op,proto3,enum=pb.WatchOp" json:"Op,omitempty"`
+	Name    string  `protobuf:"bytes,2,opt,name=Name,proto3" json:"Name,omitempty"`
+	NewName string  `protobuf:"bytes,3,opt,name=NewName,proto3" json:"NewName,omitempty"`
+}
+
+func (m *WatchEvent) Reset()      { *m = WatchEvent{} }
+func (*WatchEvent) ProtoMessage() {}
+
+type Limits struct {
+	MaxMessageSize uint32 `protobuf:"varint,1,opt,name=MaxMessageSize,proto3" json:"MaxMessageSize,omitempty"`
+	MaxChunkSize   uint32 `protobuf:"varint,2,opt,name=MaxChunkSize,proto3" json:"MaxChunkSize,omitempty"`
+	DirBatchSize   uint32 `protobuf:"varint,3,opt,name=DirBatchSize,proto3" json:"DirBatchSize,omitempty"`
+}
+
+func (m *Limits) Reset()      { *m = Limits{} }
+func (*Limits) ProtoMessage() {}
+
+type HelloRequest struct {
+	Version  uint32    `protobuf:"varint,1,opt,name=Version,proto3" json:"Version,omitempty"`
+	Features []Feature `protobuf:"varint,2,rep,name=Features,enum=pb.Feature" json:"Features,omitempty"`
+}
+
+func (m *HelloRequest) Reset()      { *m = HelloRequest{} }
+func (*HelloRequest) ProtoMessage() {}
+
+type HelloResponse struct {
+	Version  uint32    `protobuf:"varint,1,opt,name=Version,proto3" json:"Version,omitempty"`
+	Features []Feature `protobuf:"varint,2,rep,name=Features,enum=pb.Feature" json:"Features,omitempty"`
+	Limits   *Limits   `protobuf:"bytes,3,opt,name=Limits" json:"Limits,omitempty"`
+	Session  uint64    `protobuf:"varint,4,opt,name=Session,proto3" json:"Session,omitempty"`
+}
+
+func (m *HelloResponse) Reset()      { *m = HelloResponse{} }
+func (*HelloResponse) ProtoMessage() {}
+
+func (m *HelloResponse) GetLimits() *Limits {
+	if m != nil {
+		return m.Limits
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Status)(nil), "pb.Status")
 	proto.RegisterType((*Owner)(nil), "pb.Owner")
@@ -945,6 +1260,20 @@ func init() {
 	proto.RegisterType((*OpenResponse)(nil), "pb.OpenResponse")
 	proto.RegisterType((*CreateRequest)(nil), "pb.CreateRequest")
 	proto.RegisterType((*CreateResponse)(nil), "pb.CreateResponse")
+	proto.RegisterType((*ReadRequest)(nil), "pb.ReadRequest")
+	proto.RegisterType((*ReadResponse)(nil), "pb.ReadResponse")
+	proto.RegisterType((*ReadStreamRequest)(nil), "pb.ReadStreamRequest")
+	proto.RegisterType((*ReadStreamResponse)(nil), "pb.ReadStreamResponse")
+	proto.RegisterType((*WriteRequest)(nil), "pb.WriteRequest")
+	proto.RegisterType((*WriteResponse)(nil), "pb.WriteResponse")
+	proto.RegisterType((*WriteStreamRequest)(nil), "pb.WriteStreamRequest")
+	proto.RegisterType((*WriteStreamResponse)(nil), "pb.WriteStreamResponse")
+	proto.RegisterType((*FlushRequest)(nil), "pb.FlushRequest")
+	proto.RegisterType((*FlushResponse)(nil), "pb.FlushResponse")
+	proto.RegisterType((*FsyncRequest)(nil), "pb.FsyncRequest")
+	proto.RegisterType((*FsyncResponse)(nil), "pb.FsyncResponse")
+	proto.RegisterType((*ReleaseRequest)(nil), "pb.ReleaseRequest")
+	proto.RegisterType((*ReleaseResponse)(nil), "pb.ReleaseResponse")
 	proto.RegisterType((*DirEntry)(nil), "pb.DirEntry")
 	proto.RegisterType((*OpenDirRequest)(nil), "pb.OpenDirRequest")
 	proto.RegisterType((*OpenDirResponse)(nil), "pb.OpenDirResponse")
@@ -955,14 +1284,23 @@ func init() {
 	proto.RegisterType((*StatFs)(nil), "pb.StatFs")
 	proto.RegisterType((*StatFsRequest)(nil), "pb.StatFsRequest")
 	proto.RegisterType((*StatFsResponse)(nil), "pb.StatFsResponse")
+	proto.RegisterType((*WatchRequest)(nil), "pb.WatchRequest")
+	proto.RegisterType((*WatchEvent)(nil), "pb.WatchEvent")
+	proto.RegisterType((*Limits)(nil), "pb.Limits")
+	proto.RegisterType((*HelloRequest)(nil), "pb.HelloRequest")
+	proto.RegisterType((*HelloResponse)(nil), "pb.HelloResponse")
+	proto.RegisterEnum("pb.WatchOp", WatchOp_name, WatchOp_value)
+	proto.RegisterEnum("pb.Feature", Feature_name, Feature_value)
 }
 func (this *Status) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 5)
+	s := make([]string, 0, 7)
 	s = append(s, "&pb.Status{")
 	s = append(s, "Code: "+fmt.Sprintf("%#v", this.Code)+",\n")
+	s = append(s, "Path: "+fmt.Sprintf("%#v", this.Path)+",\n")
+	s = append(s, "Message: "+fmt.Sprintf("%#v", this.Message)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -1480,9 +1818,10 @@ func (this *File) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 5)
+	s := make([]string, 0, 6)
 	s = append(s, "&pb.File{")
 	s = append(s, "Data: "+fmt.Sprintf("%#v", this.Data)+",\n")
+	s = append(s, "Handle: "+fmt.Sprintf("%#v", this.Handle)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -1490,13 +1829,14 @@ func (this *OpenRequest) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 7)
+	s := make([]string, 0, 8)
 	s = append(s, "&pb.OpenRequest{")
 	s = append(s, "Name: "+fmt.Sprintf("%#v", this.Name)+",\n")
 	s = append(s, "Flags: "+fmt.Sprintf("%#v", this.Flags)+",\n")
 	if this.Context != nil {
 		s = append(s, "Context: "+fmt.Sprintf("%#v", this.Context)+",\n")
 	}
+	s = append(s, "Handle: "+fmt.Sprintf("%#v", this.Handle)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -1548,173 +1888,416 @@ func (this *CreateResponse) GoString() string {
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
-func (this *DirEntry) GoString() string {
+func (this *ReadRequest) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 6)
-	s = append(s, "&pb.DirEntry{")
-	s = append(s, "Mode: "+fmt.Sprintf("%#v", this.Mode)+",\n")
-	s = append(s, "Name: "+fmt.Sprintf("%#v", this.Name)+",\n")
+	s := make([]string, 0, 7)
+	s = append(s, "&pb.ReadRequest{")
+	s = append(s, "Handle: "+fmt.Sprintf("%#v", this.Handle)+",\n")
+	s = append(s, "Offset: "+fmt.Sprintf("%#v", this.Offset)+",\n")
+	s = append(s, "Size_: "+fmt.Sprintf("%#v", this.Size_)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
-func (this *OpenDirRequest) GoString() string {
+func (this *ReadResponse) GoString() string {
 	if this == nil {
 		return "nil"
 	}
 	s := make([]string, 0, 6)
-	s = append(s, "&pb.OpenDirRequest{")
-	s = append(s, "Name: "+fmt.Sprintf("%#v", this.Name)+",\n")
-	if this.Context != nil {
-		s = append(s, "Context: "+fmt.Sprintf("%#v", this.Context)+",\n")
+	s = append(s, "&pb.ReadResponse{")
+	s = append(s, "Data: "+fmt.Sprintf("%#v", this.Data)+",\n")
+	if this.Status != nil {
+		s = append(s, "Status: "+fmt.Sprintf("%#v", this.Status)+",\n")
 	}
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
-func (this *OpenDirResponse) GoString() string {
+func (this *ReadStreamRequest) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 6)
-	s = append(s, "&pb.OpenDirResponse{")
-	if this.Dirs != nil {
-		s = append(s, "Dirs: "+fmt.Sprintf("%#v", this.Dirs)+",\n")
+	s := make([]string, 0, 8)
+	s = append(s, "&pb.ReadStreamRequest{")
+	s = append(s, "Handle: "+fmt.Sprintf("%#v", this.Handle)+",\n")
+	s = append(s, "Offset: "+fmt.Sprintf("%#v", this.Offset)+",\n")
+	s = append(s, "Size_: "+fmt.Sprintf("%#v", this.Size_)+",\n")
+	s = append(s, "ChunkSize: "+fmt.Sprintf("%#v", this.ChunkSize)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *ReadStreamResponse) GoString() string {
+	if this == nil {
+		return "nil"
 	}
+	s := make([]string, 0, 7)
+	s = append(s, "&pb.ReadStreamResponse{")
+	s = append(s, "Data: "+fmt.Sprintf("%#v", this.Data)+",\n")
+	s = append(s, "Offset: "+fmt.Sprintf("%#v", this.Offset)+",\n")
 	if this.Status != nil {
 		s = append(s, "Status: "+fmt.Sprintf("%#v", this.Status)+",\n")
 	}
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
-func (this *SymlinkRequest) GoString() string {
+func (this *WriteRequest) GoString() string {
 	if this == nil {
 		return "nil"
 	}
 	s := make([]string, 0, 7)
-	s = append(s, "&pb.SymlinkRequest{")
-	s = append(s, "Value: "+fmt.Sprintf("%#v", this.Value)+",\n")
-	s = append(s, "LinkName: "+fmt.Sprintf("%#v", this.LinkName)+",\n")
-	if this.Context != nil {
-		s = append(s, "Context: "+fmt.Sprintf("%#v", this.Context)+",\n")
-	}
+	s = append(s, "&pb.WriteRequest{")
+	s = append(s, "Handle: "+fmt.Sprintf("%#v", this.Handle)+",\n")
+	s = append(s, "Offset: "+fmt.Sprintf("%#v", this.Offset)+",\n")
+	s = append(s, "Data: "+fmt.Sprintf("%#v", this.Data)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
-func (this *SymlinkResponse) GoString() string {
+func (this *WriteResponse) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 5)
-	s = append(s, "&pb.SymlinkResponse{")
+	s := make([]string, 0, 6)
+	s = append(s, "&pb.WriteResponse{")
+	s = append(s, "Written: "+fmt.Sprintf("%#v", this.Written)+",\n")
 	if this.Status != nil {
 		s = append(s, "Status: "+fmt.Sprintf("%#v", this.Status)+",\n")
 	}
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
-func (this *ReadlinkRequest) GoString() string {
+func (this *WriteStreamRequest) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 6)
-	s = append(s, "&pb.ReadlinkRequest{")
-	s = append(s, "Name: "+fmt.Sprintf("%#v", this.Name)+",\n")
-	if this.Context != nil {
-		s = append(s, "Context: "+fmt.Sprintf("%#v", this.Context)+",\n")
-	}
+	s := make([]string, 0, 7)
+	s = append(s, "&pb.WriteStreamRequest{")
+	s = append(s, "Handle: "+fmt.Sprintf("%#v", this.Handle)+",\n")
+	s = append(s, "Offset: "+fmt.Sprintf("%#v", this.Offset)+",\n")
+	s = append(s, "Data: "+fmt.Sprintf("%#v", this.Data)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
-func (this *ReadlinkResponse) GoString() string {
+func (this *WriteStreamResponse) GoString() string {
 	if this == nil {
 		return "nil"
 	}
 	s := make([]string, 0, 6)
-	s = append(s, "&pb.ReadlinkResponse{")
-	s = append(s, "Value: "+fmt.Sprintf("%#v", this.Value)+",\n")
+	s = append(s, "&pb.WriteStreamResponse{")
+	s = append(s, "Offset: "+fmt.Sprintf("%#v", this.Offset)+",\n")
 	if this.Status != nil {
 		s = append(s, "Status: "+fmt.Sprintf("%#v", this.Status)+",\n")
 	}
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
-func (this *StatFs) GoString() string {
+func (this *FlushRequest) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 14)
-	s = append(s, "&pb.StatFs{")
-	s = append(s, "Blocks: "+fmt.Sprintf("%#v", this.Blocks)+",\n")
-	s = append(s, "Bfree: "+fmt.Sprintf("%#v", this.Bfree)+",\n")
-	s = append(s, "Bavail: "+fmt.Sprintf("%#v", this.Bavail)+",\n")
-	s = append(s, "Files: "+fmt.Sprintf("%#v", this.Files)+",\n")
-	s = append(s, "Ffree: "+fmt.Sprintf("%#v", this.Ffree)+",\n")
-	s = append(s, "Bsize: "+fmt.Sprintf("%#v", this.Bsize)+",\n")
-	s = append(s, "NameLen: "+fmt.Sprintf("%#v", this.NameLen)+",\n")
-	s = append(s, "Frsize: "+fmt.Sprintf("%#v", this.Frsize)+",\n")
-	s = append(s, "Padding: "+fmt.Sprintf("%#v", this.Padding)+",\n")
-	s = append(s, "Spare: "+fmt.Sprintf("%#v", this.Spare)+",\n")
+	s := make([]string, 0, 5)
+	s = append(s, "&pb.FlushRequest{")
+	s = append(s, "Handle: "+fmt.Sprintf("%#v", this.Handle)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
-func (this *StatFsRequest) GoString() string {
+func (this *FlushResponse) GoString() string {
 	if this == nil {
 		return "nil"
 	}
 	s := make([]string, 0, 5)
-	s = append(s, "&pb.StatFsRequest{")
-	s = append(s, "Name: "+fmt.Sprintf("%#v", this.Name)+",\n")
+	s = append(s, "&pb.FlushResponse{")
+	if this.Status != nil {
+		s = append(s, "Status: "+fmt.Sprintf("%#v", this.Status)+",\n")
+	}
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
-func (this *StatFsResponse) GoString() string {
+func (this *FsyncRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&pb.FsyncRequest{")
+	s = append(s, "Handle: "+fmt.Sprintf("%#v", this.Handle)+",\n")
+	s = append(s, "Flags: "+fmt.Sprintf("%#v", this.Flags)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *FsyncResponse) GoString() string {
 	if this == nil {
 		return "nil"
 	}
 	s := make([]string, 0, 5)
-	s = append(s, "&pb.StatFsResponse{")
-	if this.StatFs != nil {
-		s = append(s, "StatFs: "+fmt.Sprintf("%#v", this.StatFs)+",\n")
+	s = append(s, "&pb.FsyncResponse{")
+	if this.Status != nil {
+		s = append(s, "Status: "+fmt.Sprintf("%#v", this.Status)+",\n")
 	}
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
-func valueToGoStringPathfs(v interface{}, typ string) string {
-	rv := reflect.ValueOf(v)
-	if rv.IsNil() {
+func (this *ReleaseRequest) GoString() string {
+	if this == nil {
 		return "nil"
 	}
-	pv := reflect.Indirect(rv).Interface()
-	return fmt.Sprintf("func(v %v) *%v { return &v } ( %#v )", typ, typ, pv)
+	s := make([]string, 0, 5)
+	s = append(s, "&pb.ReleaseRequest{")
+	s = append(s, "Handle: "+fmt.Sprintf("%#v", this.Handle)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
 }
-func extensionToGoStringPathfs(e map[int32]github_com_gogo_protobuf_proto.Extension) string {
-	if e == nil {
+func (this *ReleaseResponse) GoString() string {
+	if this == nil {
 		return "nil"
 	}
-	s := "map[int32]proto.Extension{"
-	keys := make([]int, 0, len(e))
-	for k := range e {
-		keys = append(keys, int(k))
-	}
-	sort.Ints(keys)
-	ss := []string{}
-	for _, k := range keys {
-		ss = append(ss, strconv.Itoa(k)+": "+e[int32(k)].GoString())
+	s := make([]string, 0, 5)
+	s = append(s, "&pb.ReleaseResponse{")
+	if this.Status != nil {
+		s = append(s, "Status: "+fmt.Sprintf("%#v", this.Status)+",\n")
 	}
-	s += strings.Join(ss, ",") + "}"
-	return s
+	s = append(s, "}")
+	return strings.Join(s, "")
 }
-
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
-
-// Client API for PathFS service
-
-type PathFSClient interface {
-	// Used for pretty printing.
-	String(ctx context.Context, in *StringRequest, opts ...grpc.CallOption) (*StringResponse, error)
+func (this *DirEntry) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&pb.DirEntry{")
+	s = append(s, "Mode: "+fmt.Sprintf("%#v", this.Mode)+",\n")
+	s = append(s, "Name: "+fmt.Sprintf("%#v", this.Name)+",\n")
+	if this.Attr != nil {
+		s = append(s, "Attr: "+fmt.Sprintf("%#v", this.Attr)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *OpenDirRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&pb.OpenDirRequest{")
+	s = append(s, "Name: "+fmt.Sprintf("%#v", this.Name)+",\n")
+	if this.Context != nil {
+		s = append(s, "Context: "+fmt.Sprintf("%#v", this.Context)+",\n")
+	}
+	s = append(s, "Plus: "+fmt.Sprintf("%#v", this.Plus)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *OpenDirResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&pb.OpenDirResponse{")
+	if this.Dirs != nil {
+		s = append(s, "Dirs: "+fmt.Sprintf("%#v", this.Dirs)+",\n")
+	}
+	if this.Status != nil {
+		s = append(s, "Status: "+fmt.Sprintf("%#v", this.Status)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *SymlinkRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&pb.SymlinkRequest{")
+	s = append(s, "Value: "+fmt.Sprintf("%#v", this.Value)+",\n")
+	s = append(s, "LinkName: "+fmt.Sprintf("%#v", this.LinkName)+",\n")
+	if this.Context != nil {
+		s = append(s, "Context: "+fmt.Sprintf("%#v", this.Context)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *SymlinkResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&pb.SymlinkResponse{")
+	if this.Status != nil {
+		s = append(s, "Status: "+fmt.Sprintf("%#v", this.Status)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *ReadlinkRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&pb.ReadlinkRequest{")
+	s = append(s, "Name: "+fmt.Sprintf("%#v", this.Name)+",\n")
+	if this.Context != nil {
+		s = append(s, "Context: "+fmt.Sprintf("%#v", this.Context)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *ReadlinkResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&pb.ReadlinkResponse{")
+	s = append(s, "Value: "+fmt.Sprintf("%#v", this.Value)+",\n")
+	if this.Status != nil {
+		s = append(s, "Status: "+fmt.Sprintf("%#v", this.Status)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *StatFs) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 14)
+	s = append(s, "&pb.StatFs{")
+	s = append(s, "Blocks: "+fmt.Sprintf("%#v", this.Blocks)+",\n")
+	s = append(s, "Bfree: "+fmt.Sprintf("%#v", this.Bfree)+",\n")
+	s = append(s, "Bavail: "+fmt.Sprintf("%#v", this.Bavail)+",\n")
+	s = append(s, "Files: "+fmt.Sprintf("%#v", this.Files)+",\n")
+	s = append(s, "Ffree: "+fmt.Sprintf("%#v", this.Ffree)+",\n")
+	s = append(s, "Bsize: "+fmt.Sprintf("%#v", this.Bsize)+",\n")
+	s = append(s, "NameLen: "+fmt.Sprintf("%#v", this.NameLen)+",\n")
+	s = append(s, "Frsize: "+fmt.Sprintf("%#v", this.Frsize)+",\n")
+	s = append(s, "Padding: "+fmt.Sprintf("%#v", this.Padding)+",\n")
+	s = append(s, "Spare: "+fmt.Sprintf("%#v", this.Spare)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *StatFsRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&pb.StatFsRequest{")
+	s = append(s, "Name: "+fmt.Sprintf("%#v", this.Name)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *StatFsResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&pb.StatFsResponse{")
+	if this.StatFs != nil {
+		s = append(s, "StatFs: "+fmt.Sprintf("%#v", this.StatFs)+",\n")
+	}
+	s = append(s, "ReadOnly: "+fmt.Sprintf("%#v", this.ReadOnly)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *WatchRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&pb.WatchRequest{")
+	s = append(s, "Name: "+fmt.Sprintf("%#v", this.Name)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *WatchEvent) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&pb.WatchEvent{")
+	s = append(s, "Op: "+fmt.Sprintf("%#v", this.Op)+",\n")
+	s = append(s, "Name: "+fmt.Sprintf("%#v", this.Name)+",\n")
+	s = append(s, "NewName: "+fmt.Sprintf("%#v", this.NewName)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *Limits) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&pb.Limits{")
+	s = append(s, "MaxMessageSize: "+fmt.Sprintf("%#v", this.MaxMessageSize)+",\n")
+	s = append(s, "MaxChunkSize: "+fmt.Sprintf("%#v", this.MaxChunkSize)+",\n")
+	s = append(s, "DirBatchSize: "+fmt.Sprintf("%#v", this.DirBatchSize)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *HelloRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&pb.HelloRequest{")
+	s = append(s, "Version: "+fmt.Sprintf("%#v", this.Version)+",\n")
+	s = append(s, "Features: "+fmt.Sprintf("%#v", this.Features)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *HelloResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 8)
+	s = append(s, "&pb.HelloResponse{")
+	s = append(s, "Version: "+fmt.Sprintf("%#v", this.Version)+",\n")
+	s = append(s, "Features: "+fmt.Sprintf("%#v", this.Features)+",\n")
+	if this.Limits != nil {
+		s = append(s, "Limits: "+fmt.Sprintf("%#v", this.Limits)+",\n")
+	}
+	s = append(s, "Session: "+fmt.Sprintf("%#v", this.Session)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func valueToGoStringPathfs(v interface{}, typ string) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
+	return fmt.Sprintf("func(v %v) *%v { return &v } ( %#v )", typ, typ, pv)
+}
+func extensionToGoStringPathfs(e map[int32]github_com_gogo_protobuf_proto.Extension) string {
+	if e == nil {
+		return "nil"
+	}
+	s := "map[int32]proto.Extension{"
+	keys := make([]int, 0, len(e))
+	for k := range e {
+		keys = append(keys, int(k))
+	}
+	sort.Ints(keys)
+	ss := []string{}
+	for _, k := range keys {
+		ss = append(ss, strconv.Itoa(k)+": "+e[int32(k)].GoString())
+	}
+	s += strings.Join(ss, ",") + "}"
+	return s
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for PathFS service
+
+type PathFSClient interface {
+	// Exchanges the protocol version, features and limits of both
+	// sides.  Clients call it before using anything beyond the
+	// calls of protocol version 1 without features, which every
+	// server implements.
+	Hello(ctx context.Context, in *HelloRequest, opts ...grpc.CallOption) (*HelloResponse, error)
+	// Used for pretty printing.
+	String(ctx context.Context, in *StringRequest, opts ...grpc.CallOption) (*StringResponse, error)
 	// If called, provide debug output through the log package.
 	SetDebug(ctx context.Context, in *SetDebugRequest, opts ...grpc.CallOption) (*SetDebugResponse, error)
 	// Attributes.  This function is the main entry point, through
@@ -1744,12 +2327,36 @@ type PathFSClient interface {
 	// should be updated too.
 	Open(ctx context.Context, in *OpenRequest, opts ...grpc.CallOption) (*OpenResponse, error)
 	Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CreateResponse, error)
+	// Operations on files returned by Open and Create.  Files are
+	// addressed by the handle from File.Handle and stay open on the
+	// server until Release is called.
+	Read(ctx context.Context, in *ReadRequest, opts ...grpc.CallOption) (*ReadResponse, error)
+	Write(ctx context.Context, in *WriteRequest, opts ...grpc.CallOption) (*WriteResponse, error)
+	Flush(ctx context.Context, in *FlushRequest, opts ...grpc.CallOption) (*FlushResponse, error)
+	Fsync(ctx context.Context, in *FsyncRequest, opts ...grpc.CallOption) (*FsyncResponse, error)
+	Release(ctx context.Context, in *ReleaseRequest, opts ...grpc.CallOption) (*ReleaseResponse, error)
+	// Sends Size bytes of an open file starting at Offset in chunks
+	// of at most ChunkSize bytes.  A Size of 0 reads until the end
+	// of the file.
+	ReadStream(ctx context.Context, in *ReadStreamRequest, opts ...grpc.CallOption) (PathFS_ReadStreamClient, error)
+	// Writes a sequence of chunks to the open file given by their
+	// Handle, which is the same for all of them.  The response
+	// carries the offset just past the last chunk written, from
+	// which writes can be resumed if the stream breaks.
+	WriteStream(ctx context.Context, opts ...grpc.CallOption) (PathFS_WriteStreamClient, error)
 	// Directory handling
 	OpenDir(ctx context.Context, in *OpenDirRequest, opts ...grpc.CallOption) (*OpenDirResponse, error)
+	// Like OpenDir, but sends the entries in batches, so directories
+	// of any size fit into the message size limit.  An error is
+	// reported in the Status of the last message.
+	OpenDirStream(ctx context.Context, in *OpenDirRequest, opts ...grpc.CallOption) (PathFS_OpenDirStreamClient, error)
 	// Symlinks.
 	Symlink(ctx context.Context, in *SymlinkRequest, opts ...grpc.CallOption) (*SymlinkResponse, error)
 	Readlink(ctx context.Context, in *ReadlinkRequest, opts ...grpc.CallOption) (*ReadlinkResponse, error)
 	StatFs(ctx context.Context, in *StatFsRequest, opts ...grpc.CallOption) (*StatFsResponse, error)
+	// Sends changes made to the exported file system, starting with
+	// a Rescan event once the watch is established.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (PathFS_WatchClient, error)
 }
 
 type pathFSClient struct {
@@ -1760,6 +2367,15 @@ func NewPathFSClient(cc *grpc.ClientConn) PathFSClient {
 	return &pathFSClient{cc}
 }
 
+func (c *pathFSClient) Hello(ctx context.Context, in *HelloRequest, opts ...grpc.CallOption) (*HelloResponse, error) {
+	out := new(HelloResponse)
+	err := grpc.Invoke(ctx, "/pb.PathFS/Hello", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *pathFSClient) String(ctx context.Context, in *StringRequest, opts ...grpc.CallOption) (*StringResponse, error) {
 	out := new(StringResponse)
 	err := grpc.Invoke(ctx, "/pb.PathFS/String", in, out, c.cc, opts...)
@@ -1940,6 +2556,117 @@ func (c *pathFSClient) Create(ctx context.Context, in *CreateRequest, opts ...gr
 	return out, nil
 }
 
+func (c *pathFSClient) Read(ctx context.Context, in *ReadRequest, opts ...grpc.CallOption) (*ReadResponse, error) {
+	out := new(ReadResponse)
+	err := grpc.Invoke(ctx, "/pb.PathFS/Read", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pathFSClient) Write(ctx context.Context, in *WriteRequest, opts ...grpc.CallOption) (*WriteResponse, error) {
+	out := new(WriteResponse)
+	err := grpc.Invoke(ctx, "/pb.PathFS/Write", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pathFSClient) Flush(ctx context.Context, in *FlushRequest, opts ...grpc.CallOption) (*FlushResponse, error) {
+	out := new(FlushResponse)
+	err := grpc.Invoke(ctx, "/pb.PathFS/Flush", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pathFSClient) Fsync(ctx context.Context, in *FsyncRequest, opts ...grpc.CallOption) (*FsyncResponse, error) {
+	out := new(FsyncResponse)
+	err := grpc.Invoke(ctx, "/pb.PathFS/Fsync", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pathFSClient) Release(ctx context.Context, in *ReleaseRequest, opts ...grpc.CallOption) (*ReleaseResponse, error) {
+	out := new(ReleaseResponse)
+	err := grpc.Invoke(ctx, "/pb.PathFS/Release", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pathFSClient) ReadStream(ctx context.Context, in *ReadStreamRequest, opts ...grpc.CallOption) (PathFS_ReadStreamClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_PathFS_serviceDesc.Streams[0], c.cc, "/pb.PathFS/ReadStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &pathFSReadStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type PathFS_ReadStreamClient interface {
+	Recv() (*ReadStreamResponse, error)
+	grpc.ClientStream
+}
+
+type pathFSReadStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *pathFSReadStreamClient) Recv() (*ReadStreamResponse, error) {
+	m := new(ReadStreamResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *pathFSClient) WriteStream(ctx context.Context, opts ...grpc.CallOption) (PathFS_WriteStreamClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_PathFS_serviceDesc.Streams[1], c.cc, "/pb.PathFS/WriteStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &pathFSWriteStreamClient{stream}
+	return x, nil
+}
+
+type PathFS_WriteStreamClient interface {
+	Send(*WriteStreamRequest) error
+	CloseAndRecv() (*WriteStreamResponse, error)
+	grpc.ClientStream
+}
+
+type pathFSWriteStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *pathFSWriteStreamClient) Send(m *WriteStreamRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *pathFSWriteStreamClient) CloseAndRecv() (*WriteStreamResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(WriteStreamResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *pathFSClient) OpenDir(ctx context.Context, in *OpenDirRequest, opts ...grpc.CallOption) (*OpenDirResponse, error) {
 	out := new(OpenDirResponse)
 	err := grpc.Invoke(ctx, "/pb.PathFS/OpenDir", in, out, c.cc, opts...)
@@ -1949,6 +2676,38 @@ func (c *pathFSClient) OpenDir(ctx context.Context, in *OpenDirRequest, opts ...
 	return out, nil
 }
 
+func (c *pathFSClient) OpenDirStream(ctx context.Context, in *OpenDirRequest, opts ...grpc.CallOption) (PathFS_OpenDirStreamClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_PathFS_serviceDesc.Streams[2], c.cc, "/pb.PathFS/OpenDirStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &pathFSOpenDirStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type PathFS_OpenDirStreamClient interface {
+	Recv() (*OpenDirResponse, error)
+	grpc.ClientStream
+}
+
+type pathFSOpenDirStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *pathFSOpenDirStreamClient) Recv() (*OpenDirResponse, error) {
+	m := new(OpenDirResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *pathFSClient) Symlink(ctx context.Context, in *SymlinkRequest, opts ...grpc.CallOption) (*SymlinkResponse, error) {
 	out := new(SymlinkResponse)
 	err := grpc.Invoke(ctx, "/pb.PathFS/Symlink", in, out, c.cc, opts...)
@@ -1976,9 +2735,46 @@ func (c *pathFSClient) StatFs(ctx context.Context, in *StatFsRequest, opts ...gr
 	return out, nil
 }
 
+func (c *pathFSClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (PathFS_WatchClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_PathFS_serviceDesc.Streams[3], c.cc, "/pb.PathFS/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &pathFSWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type PathFS_WatchClient interface {
+	Recv() (*WatchEvent, error)
+	grpc.ClientStream
+}
+
+type pathFSWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *pathFSWatchClient) Recv() (*WatchEvent, error) {
+	m := new(WatchEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // Server API for PathFS service
 
 type PathFSServer interface {
+	// Exchanges the protocol version, features and limits of both
+	// sides.  Clients call it before using anything beyond the
+	// calls of protocol version 1 without features, which every
+	// server implements.
+	Hello(context.Context, *HelloRequest) (*HelloResponse, error)
 	// Used for pretty printing.
 	String(context.Context, *StringRequest) (*StringResponse, error)
 	// If called, provide debug output through the log package.
@@ -2010,310 +2806,679 @@ type PathFSServer interface {
 	// should be updated too.
 	Open(context.Context, *OpenRequest) (*OpenResponse, error)
 	Create(context.Context, *CreateRequest) (*CreateResponse, error)
+	// Operations on files returned by Open and Create.  Files are
+	// addressed by the handle from File.Handle and stay open on the
+	// server until Release is called.
+	Read(context.Context, *ReadRequest) (*ReadResponse, error)
+	Write(context.Context, *WriteRequest) (*WriteResponse, error)
+	Flush(context.Context, *FlushRequest) (*FlushResponse, error)
+	Fsync(context.Context, *FsyncRequest) (*FsyncResponse, error)
+	Release(context.Context, *ReleaseRequest) (*ReleaseResponse, error)
+	// Sends Size bytes of an open file starting at Offset in chunks
+	// of at most ChunkSize bytes.  A Size of 0 reads until the end
+	// of the file.
+	ReadStream(*ReadStreamRequest, PathFS_ReadStreamServer) error
+	// Writes a sequence of chunks to the open file given by their
+	// Handle, which is the same for all of them.  The response
+	// carries the offset just past the last chunk written, from
+	// which writes can be resumed if the stream breaks.
+	WriteStream(PathFS_WriteStreamServer) error
 	// Directory handling
 	OpenDir(context.Context, *OpenDirRequest) (*OpenDirResponse, error)
+	// Like OpenDir, but sends the entries in batches, so directories
+	// of any size fit into the message size limit.  An error is
+	// reported in the Status of the last message.
+	OpenDirStream(*OpenDirRequest, PathFS_OpenDirStreamServer) error
 	// Symlinks.
 	Symlink(context.Context, *SymlinkRequest) (*SymlinkResponse, error)
 	Readlink(context.Context, *ReadlinkRequest) (*ReadlinkResponse, error)
 	StatFs(context.Context, *StatFsRequest) (*StatFsResponse, error)
+	// Sends changes made to the exported file system, starting with
+	// a Rescan event once the watch is established.
+	Watch(*WatchRequest, PathFS_WatchServer) error
 }
 
 func RegisterPathFSServer(s *grpc.Server, srv PathFSServer) {
 	s.RegisterService(&_PathFS_serviceDesc, srv)
 }
 
-func _PathFS_String_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(StringRequest)
+func _PathFS_Hello_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HelloRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).String(ctx, in)
-	if err != nil {
+	if interceptor == nil {
+		return srv.(PathFSServer).Hello(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/Hello",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).Hello(ctx, req.(*HelloRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PathFS_String_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StringRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return out, nil
+	if interceptor == nil {
+		return srv.(PathFSServer).String(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/String",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).String(ctx, req.(*StringRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PathFS_SetDebug_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+func _PathFS_SetDebug_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(SetDebugRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).SetDebug(ctx, in)
-	if err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(PathFSServer).SetDebug(ctx, in)
 	}
-	return out, nil
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/SetDebug",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).SetDebug(ctx, req.(*SetDebugRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PathFS_GetAttr_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+func _PathFS_GetAttr_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetAttrRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).GetAttr(ctx, in)
-	if err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(PathFSServer).GetAttr(ctx, in)
 	}
-	return out, nil
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/GetAttr",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).GetAttr(ctx, req.(*GetAttrRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PathFS_Chmod_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+func _PathFS_Chmod_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ChmodRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).Chmod(ctx, in)
-	if err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(PathFSServer).Chmod(ctx, in)
 	}
-	return out, nil
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/Chmod",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).Chmod(ctx, req.(*ChmodRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PathFS_Chown_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+func _PathFS_Chown_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ChownRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).Chown(ctx, in)
-	if err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(PathFSServer).Chown(ctx, in)
 	}
-	return out, nil
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/Chown",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).Chown(ctx, req.(*ChownRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PathFS_Utimens_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+func _PathFS_Utimens_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(UtimensRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).Utimens(ctx, in)
-	if err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(PathFSServer).Utimens(ctx, in)
 	}
-	return out, nil
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/Utimens",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).Utimens(ctx, req.(*UtimensRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PathFS_Truncate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+func _PathFS_Truncate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(TruncateRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).Truncate(ctx, in)
-	if err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(PathFSServer).Truncate(ctx, in)
 	}
-	return out, nil
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/Truncate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).Truncate(ctx, req.(*TruncateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PathFS_Access_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+func _PathFS_Access_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(AccessRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).Access(ctx, in)
-	if err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(PathFSServer).Access(ctx, in)
 	}
-	return out, nil
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/Access",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).Access(ctx, req.(*AccessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PathFS_Link_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+func _PathFS_Link_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(LinkRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).Link(ctx, in)
-	if err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(PathFSServer).Link(ctx, in)
 	}
-	return out, nil
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/Link",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).Link(ctx, req.(*LinkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PathFS_Mkdir_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+func _PathFS_Mkdir_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(MkdirRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).Mkdir(ctx, in)
-	if err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(PathFSServer).Mkdir(ctx, in)
 	}
-	return out, nil
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/Mkdir",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).Mkdir(ctx, req.(*MkdirRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PathFS_Mknod_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+func _PathFS_Mknod_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(MknodRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).Mknod(ctx, in)
-	if err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(PathFSServer).Mknod(ctx, in)
 	}
-	return out, nil
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/Mknod",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).Mknod(ctx, req.(*MknodRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PathFS_Rename_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+func _PathFS_Rename_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(RenameRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).Rename(ctx, in)
-	if err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(PathFSServer).Rename(ctx, in)
 	}
-	return out, nil
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/Rename",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).Rename(ctx, req.(*RenameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PathFS_Rmdir_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+func _PathFS_Rmdir_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(RmdirRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).Rmdir(ctx, in)
-	if err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(PathFSServer).Rmdir(ctx, in)
 	}
-	return out, nil
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/Rmdir",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).Rmdir(ctx, req.(*RmdirRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PathFS_Unlink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+func _PathFS_Unlink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(UnlinkRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).Unlink(ctx, in)
-	if err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(PathFSServer).Unlink(ctx, in)
 	}
-	return out, nil
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/Unlink",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).Unlink(ctx, req.(*UnlinkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PathFS_GetXAttr_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+func _PathFS_GetXAttr_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetXAttrRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).GetXAttr(ctx, in)
-	if err != nil {
+	if interceptor == nil {
+		return srv.(PathFSServer).GetXAttr(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/GetXAttr",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).GetXAttr(ctx, req.(*GetXAttrRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PathFS_ListXAttr_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListXAttrRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PathFSServer).ListXAttr(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/ListXAttr",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).ListXAttr(ctx, req.(*ListXAttrRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PathFS_RemoveXAttr_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveXAttrRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PathFSServer).RemoveXAttr(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/RemoveXAttr",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).RemoveXAttr(ctx, req.(*RemoveXAttrRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PathFS_SetXAttr_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetXAttrRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return out, nil
+	if interceptor == nil {
+		return srv.(PathFSServer).SetXAttr(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/SetXAttr",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).SetXAttr(ctx, req.(*SetXAttrRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PathFS_Open_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OpenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PathFSServer).Open(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/Open",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).Open(ctx, req.(*OpenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PathFS_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PathFSServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/Create",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).Create(ctx, req.(*CreateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PathFS_Read_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PathFSServer).Read(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/Read",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).Read(ctx, req.(*ReadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PathFS_ListXAttr_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(ListXAttrRequest)
+func _PathFS_Write_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WriteRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).ListXAttr(ctx, in)
-	if err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(PathFSServer).Write(ctx, in)
 	}
-	return out, nil
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/Write",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).Write(ctx, req.(*WriteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PathFS_RemoveXAttr_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(RemoveXAttrRequest)
+func _PathFS_Flush_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FlushRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).RemoveXAttr(ctx, in)
-	if err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(PathFSServer).Flush(ctx, in)
 	}
-	return out, nil
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/Flush",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).Flush(ctx, req.(*FlushRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PathFS_SetXAttr_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(SetXAttrRequest)
+func _PathFS_Fsync_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FsyncRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).SetXAttr(ctx, in)
-	if err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(PathFSServer).Fsync(ctx, in)
 	}
-	return out, nil
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/Fsync",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).Fsync(ctx, req.(*FsyncRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PathFS_Open_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(OpenRequest)
+func _PathFS_Release_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReleaseRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).Open(ctx, in)
-	if err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(PathFSServer).Release(ctx, in)
 	}
-	return out, nil
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/Release",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).Release(ctx, req.(*ReleaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PathFS_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(CreateRequest)
-	if err := dec(in); err != nil {
-		return nil, err
+func _PathFS_ReadStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ReadStreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
 	}
-	out, err := srv.(PathFSServer).Create(ctx, in)
-	if err != nil {
+	return srv.(PathFSServer).ReadStream(m, &pathFSReadStreamServer{stream})
+}
+
+type PathFS_ReadStreamServer interface {
+	Send(*ReadStreamResponse) error
+	grpc.ServerStream
+}
+
+type pathFSReadStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *pathFSReadStreamServer) Send(m *ReadStreamResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _PathFS_WriteStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(PathFSServer).WriteStream(&pathFSWriteStreamServer{stream})
+}
+
+type PathFS_WriteStreamServer interface {
+	SendAndClose(*WriteStreamResponse) error
+	Recv() (*WriteStreamRequest, error)
+	grpc.ServerStream
+}
+
+type pathFSWriteStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *pathFSWriteStreamServer) SendAndClose(m *WriteStreamResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *pathFSWriteStreamServer) Recv() (*WriteStreamRequest, error) {
+	m := new(WriteStreamRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
-	return out, nil
+	return m, nil
 }
 
-func _PathFS_OpenDir_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+func _PathFS_OpenDir_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(OpenDirRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).OpenDir(ctx, in)
-	if err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(PathFSServer).OpenDir(ctx, in)
 	}
-	return out, nil
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/OpenDir",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).OpenDir(ctx, req.(*OpenDirRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PathFS_OpenDirStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(OpenDirRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PathFSServer).OpenDirStream(m, &pathFSOpenDirStreamServer{stream})
+}
+
+type PathFS_OpenDirStreamServer interface {
+	Send(*OpenDirResponse) error
+	grpc.ServerStream
 }
 
-func _PathFS_Symlink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+type pathFSOpenDirStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *pathFSOpenDirStreamServer) Send(m *OpenDirResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _PathFS_Symlink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(SymlinkRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).Symlink(ctx, in)
-	if err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(PathFSServer).Symlink(ctx, in)
 	}
-	return out, nil
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/Symlink",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).Symlink(ctx, req.(*SymlinkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PathFS_Readlink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+func _PathFS_Readlink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ReadlinkRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).Readlink(ctx, in)
-	if err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(PathFSServer).Readlink(ctx, in)
 	}
-	return out, nil
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/Readlink",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).Readlink(ctx, req.(*ReadlinkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PathFS_StatFs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+func _PathFS_StatFs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(StatFsRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(PathFSServer).StatFs(ctx, in)
-	if err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(PathFSServer).StatFs(ctx, in)
 	}
-	return out, nil
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.PathFS/StatFs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PathFSServer).StatFs(ctx, req.(*StatFsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PathFS_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PathFSServer).Watch(m, &pathFSWatchServer{stream})
+}
+
+type PathFS_WatchServer interface {
+	Send(*WatchEvent) error
+	grpc.ServerStream
+}
+
+type pathFSWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *pathFSWatchServer) Send(m *WatchEvent) error {
+	return x.ServerStream.SendMsg(m)
 }
 
 var _PathFS_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "pb.PathFS",
 	HandlerType: (*PathFSServer)(nil),
 	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Hello",
+			Handler:    _PathFS_Hello_Handler,
+		},
 		{
 			MethodName: "String",
 			Handler:    _PathFS_String_Handler,
@@ -2394,6 +3559,26 @@ var _PathFS_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Create",
 			Handler:    _PathFS_Create_Handler,
 		},
+		{
+			MethodName: "Read",
+			Handler:    _PathFS_Read_Handler,
+		},
+		{
+			MethodName: "Write",
+			Handler:    _PathFS_Write_Handler,
+		},
+		{
+			MethodName: "Flush",
+			Handler:    _PathFS_Flush_Handler,
+		},
+		{
+			MethodName: "Fsync",
+			Handler:    _PathFS_Fsync_Handler,
+		},
+		{
+			MethodName: "Release",
+			Handler:    _PathFS_Release_Handler,
+		},
 		{
 			MethodName: "OpenDir",
 			Handler:    _PathFS_OpenDir_Handler,
@@ -2411,7 +3596,29 @@ var _PathFS_serviceDesc = grpc.ServiceDesc{
 			Handler:    _PathFS_StatFs_Handler,
 		},
 	},
-	Streams: []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ReadStream",
+			Handler:       _PathFS_ReadStream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WriteStream",
+			Handler:       _PathFS_WriteStream_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "OpenDirStream",
+			Handler:       _PathFS_OpenDirStream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Watch",
+			Handler:       _PathFS_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pathfs.proto",
 }
 
 func (this *Status) String() string {
@@ -2420,6 +3627,8 @@ func (this *Status) String() string {
 	}
 	s := strings.Join([]string{`&Status{`,
 		`Code:` + fmt.Sprintf("%v", this.Code) + `,`,
+		`Path:` + fmt.Sprintf("%v", this.Path) + `,`,
+		`Message:` + fmt.Sprintf("%v", this.Message) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -2870,6 +4079,7 @@ func (this *File) String() string {
 	}
 	s := strings.Join([]string{`&File{`,
 		`Data:` + fmt.Sprintf("%v", this.Data) + `,`,
+		`Handle:` + fmt.Sprintf("%v", this.Handle) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -2882,6 +4092,7 @@ func (this *OpenRequest) String() string {
 		`Name:` + fmt.Sprintf("%v", this.Name) + `,`,
 		`Flags:` + fmt.Sprintf("%v", this.Flags) + `,`,
 		`Context:` + strings.Replace(fmt.Sprintf("%v", this.Context), "Context", "Context", 1) + `,`,
+		`Handle:` + fmt.Sprintf("%v", this.Handle) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -2922,6 +4133,161 @@ func (this *CreateResponse) String() string {
 	}, "")
 	return s
 }
+func (this *ReadRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&ReadRequest{`,
+		`Handle:` + fmt.Sprintf("%v", this.Handle) + `,`,
+		`Offset:` + fmt.Sprintf("%v", this.Offset) + `,`,
+		`Size_:` + fmt.Sprintf("%v", this.Size_) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *ReadResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&ReadResponse{`,
+		`Data:` + fmt.Sprintf("%v", this.Data) + `,`,
+		`Status:` + strings.Replace(fmt.Sprintf("%v", this.Status), "Status", "Status", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *ReadStreamRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&ReadStreamRequest{`,
+		`Handle:` + fmt.Sprintf("%v", this.Handle) + `,`,
+		`Offset:` + fmt.Sprintf("%v", this.Offset) + `,`,
+		`Size_:` + fmt.Sprintf("%v", this.Size_) + `,`,
+		`ChunkSize:` + fmt.Sprintf("%v", this.ChunkSize) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *ReadStreamResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&ReadStreamResponse{`,
+		`Data:` + fmt.Sprintf("%v", this.Data) + `,`,
+		`Offset:` + fmt.Sprintf("%v", this.Offset) + `,`,
+		`Status:` + strings.Replace(fmt.Sprintf("%v", this.Status), "Status", "Status", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *WriteRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&WriteRequest{`,
+		`Handle:` + fmt.Sprintf("%v", this.Handle) + `,`,
+		`Offset:` + fmt.Sprintf("%v", this.Offset) + `,`,
+		`Data:` + fmt.Sprintf("%v", this.Data) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *WriteResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&WriteResponse{`,
+		`Written:` + fmt.Sprintf("%v", this.Written) + `,`,
+		`Status:` + strings.Replace(fmt.Sprintf("%v", this.Status), "Status", "Status", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *WriteStreamRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&WriteStreamRequest{`,
+		`Handle:` + fmt.Sprintf("%v", this.Handle) + `,`,
+		`Offset:` + fmt.Sprintf("%v", this.Offset) + `,`,
+		`Data:` + fmt.Sprintf("%v", this.Data) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *WriteStreamResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&WriteStreamResponse{`,
+		`Offset:` + fmt.Sprintf("%v", this.Offset) + `,`,
+		`Status:` + strings.Replace(fmt.Sprintf("%v", this.Status), "Status", "Status", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *FlushRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&FlushRequest{`,
+		`Handle:` + fmt.Sprintf("%v", this.Handle) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *FlushResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&FlushResponse{`,
+		`Status:` + strings.Replace(fmt.Sprintf("%v", this.Status), "Status", "Status", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *FsyncRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&FsyncRequest{`,
+		`Handle:` + fmt.Sprintf("%v", this.Handle) + `,`,
+		`Flags:` + fmt.Sprintf("%v", this.Flags) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *FsyncResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&FsyncResponse{`,
+		`Status:` + strings.Replace(fmt.Sprintf("%v", this.Status), "Status", "Status", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *ReleaseRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&ReleaseRequest{`,
+		`Handle:` + fmt.Sprintf("%v", this.Handle) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *ReleaseResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&ReleaseResponse{`,
+		`Status:` + strings.Replace(fmt.Sprintf("%v", this.Status), "Status", "Status", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
 func (this *DirEntry) String() string {
 	if this == nil {
 		return "nil"
@@ -2929,6 +4295,7 @@ func (this *DirEntry) String() string {
 	s := strings.Join([]string{`&DirEntry{`,
 		`Mode:` + fmt.Sprintf("%v", this.Mode) + `,`,
 		`Name:` + fmt.Sprintf("%v", this.Name) + `,`,
+		`Attr:` + strings.Replace(fmt.Sprintf("%v", this.Attr), "Attr", "Attr", 1) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -2940,6 +4307,7 @@ func (this *OpenDirRequest) String() string {
 	s := strings.Join([]string{`&OpenDirRequest{`,
 		`Name:` + fmt.Sprintf("%v", this.Name) + `,`,
 		`Context:` + strings.Replace(fmt.Sprintf("%v", this.Context), "Context", "Context", 1) + `,`,
+		`Plus:` + fmt.Sprintf("%v", this.Plus) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -3034,6 +4402,65 @@ func (this *StatFsResponse) String() string {
 	}
 	s := strings.Join([]string{`&StatFsResponse{`,
 		`StatFs:` + strings.Replace(fmt.Sprintf("%v", this.StatFs), "StatFs", "StatFs", 1) + `,`,
+		`ReadOnly:` + fmt.Sprintf("%v", this.ReadOnly) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *WatchRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&WatchRequest{`,
+		`Name:` + fmt.Sprintf("%v", this.Name) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *WatchEvent) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&WatchEvent{`,
+		`Op:` + fmt.Sprintf("%v", this.Op) + `,`,
+		`Name:` + fmt.Sprintf("%v", this.Name) + `,`,
+		`NewName:` + fmt.Sprintf("%v", this.NewName) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *Limits) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&Limits{`,
+		`MaxMessageSize:` + fmt.Sprintf("%v", this.MaxMessageSize) + `,`,
+		`MaxChunkSize:` + fmt.Sprintf("%v", this.MaxChunkSize) + `,`,
+		`DirBatchSize:` + fmt.Sprintf("%v", this.DirBatchSize) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *HelloRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&HelloRequest{`,
+		`Version:` + fmt.Sprintf("%v", this.Version) + `,`,
+		`Features:` + fmt.Sprintf("%v", this.Features) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *HelloResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&HelloResponse{`,
+		`Version:` + fmt.Sprintf("%v", this.Version) + `,`,
+		`Features:` + fmt.Sprintf("%v", this.Features) + `,`,
+		`Limits:` + strings.Replace(fmt.Sprintf("%v", this.Limits), "Limits", "Limits", 1) + `,`,
+		`Session:` + fmt.Sprintf("%v", this.Session) + `,`,
 		`}`,
 	}, "")
 	return s
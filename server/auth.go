@@ -0,0 +1,223 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/LK4D4/grfuse/pb"
+	"github.com/LK4D4/grfuse/tlsutil"
+	"github.com/hanwen/go-fuse/fuse"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// IDRange is a range of uids or gids, First and Last included.
+type IDRange struct {
+	First, Last uint32
+}
+
+func (r IDRange) contains(id uint32) bool {
+	return r.First <= id && id <= r.Last
+}
+
+// Principal is an authenticated client of the server.
+type Principal struct {
+	Name string
+	// UIDs and GIDs are the owners the principal may act as. Nil
+	// allows any.
+	UIDs []IDRange
+	GIDs []IDRange
+}
+
+func inRanges(ranges []IDRange, id uint32) bool {
+	if ranges == nil {
+		return true
+	}
+	for _, r := range ranges {
+		if r.contains(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator identifies the client making a call.
+type Authenticator interface {
+	// Authenticate returns the principal making the call of ctx, or an
+	// error if it's unknown.
+	Authenticate(ctx context.Context) (*Principal, error)
+}
+
+// TokenAuth authenticates clients by the bearer token they send in the
+// authorization metadata, as grpcfs.BearerToken does.
+type TokenAuth map[string]*Principal
+
+func (a TokenAuth) Authenticate(ctx context.Context) (*Principal, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md["authorization"] {
+		if !strings.HasPrefix(v, "Bearer ") {
+			continue
+		}
+		if p, ok := a[strings.TrimPrefix(v, "Bearer ")]; ok {
+			return p, nil
+		}
+	}
+	return nil, grpc.Errorf(codes.Unauthenticated, "no valid bearer token")
+}
+
+// TLSAuth authenticates clients by the common name of the certificate they
+// presented, which requires mutual TLS as set up by tlsutil.ServerOption.
+type TLSAuth map[string]*Principal
+
+func (a TLSAuth) Authenticate(ctx context.Context) (*Principal, error) {
+	cert, ok := tlsutil.PeerCertificate(ctx)
+	if !ok {
+		return nil, grpc.Errorf(codes.Unauthenticated, "no client certificate")
+	}
+	if p, ok := a[cert.Subject.CommonName]; ok {
+		return p, nil
+	}
+	return nil, grpc.Errorf(codes.Unauthenticated, "unknown client %q", cert.Subject.CommonName)
+}
+
+// Auth authenticates every call to the server through its interceptors:
+//
+//	auth := &server.Auth{Authenticator: server.TLSAuth(principals)}
+//	s := grpc.NewServer(
+//		grpc.UnaryInterceptor(auth.UnaryServerInterceptor()),
+//		grpc.StreamInterceptor(auth.StreamServerInterceptor()),
+//	)
+//
+// Calls of unknown clients fail with codes.Unauthenticated. Calls on
+// behalf of owners outside the principal's ranges fail with
+// codes.PermissionDenied, unless Rewrite is set.
+type Auth struct {
+	Authenticator Authenticator
+	// Rewrite makes calls with an owner outside the principal's ranges
+	// act as the first uid and gid of the ranges instead of failing.
+	Rewrite bool
+}
+
+// checkOwner checks the context of a call made by p, rewriting it if
+// allowed. A call without an owner is checked as acting as root, and
+// principals with ranges have to send a context.
+func (a *Auth) checkOwner(p *Principal, gctx *pb.Context) error {
+	if p.UIDs == nil && p.GIDs == nil {
+		return nil
+	}
+	if gctx == nil {
+		return grpc.Errorf(codes.PermissionDenied, "%s has to send a context", p.Name)
+	}
+	if gctx.Owner == nil {
+		gctx.Owner = &pb.Owner{}
+	}
+	uidOK := inRanges(p.UIDs, gctx.Owner.Uid)
+	gidOK := inRanges(p.GIDs, gctx.Owner.Gid)
+	if uidOK && gidOK {
+		return nil
+	}
+	if !a.Rewrite || len(p.UIDs) == 0 && !uidOK || len(p.GIDs) == 0 && !gidOK {
+		return grpc.Errorf(codes.PermissionDenied, "%s may not act as %d:%d", p.Name, gctx.Owner.Uid, gctx.Owner.Gid)
+	}
+	if !uidOK {
+		gctx.Owner.Uid = p.UIDs[0].First
+	}
+	if !gidOK {
+		gctx.Owner.Gid = p.GIDs[0].First
+	}
+	return nil
+}
+
+// unchangedID is the uid or gid given to Chown to leave it alone.
+const unchangedID = ^uint32(0)
+
+// check checks a request of p.
+func (a *Auth) check(p *Principal, req interface{}) error {
+	if r, ok := req.(*pb.ChownRequest); ok {
+		if r.UID != unchangedID && !inRanges(p.UIDs, r.UID) || r.GID != unchangedID && !inRanges(p.GIDs, r.GID) {
+			return grpc.Errorf(codes.PermissionDenied, "%s may not chown to %d:%d", p.Name, r.UID, r.GID)
+		}
+	}
+	if r, ok := req.(interface {
+		GetContext() *pb.Context
+	}); ok {
+		return a.checkOwner(p, r.GetContext())
+	}
+	return nil
+}
+
+func (a *Auth) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		p, err := a.Authenticator.Authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := a.check(p, req); err != nil {
+			return nil, err
+		}
+		return handler(withPrincipal(ctx, p), req)
+	}
+}
+
+func (a *Auth) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		p, err := a.Authenticator.Authenticate(ss.Context())
+		if err != nil {
+			return err
+		}
+		ctx := withPrincipal(ss.Context(), p)
+		return handler(srv, &authStream{ServerStream: ss, ctx: ctx, auth: a, principal: p})
+	}
+}
+
+// authStream checks the requests received on a stream.
+type authStream struct {
+	grpc.ServerStream
+	ctx       context.Context
+	auth      *Auth
+	principal *Principal
+}
+
+func (s *authStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *authStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.auth.check(s.principal, m)
+}
+
+type principalKey struct{}
+
+// withPrincipal returns a context for the calls of p.
+func withPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal of a call, if it was
+// authenticated.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// principalName returns the name of the principal of ctx, or "" if the
+// call wasn't authenticated.
+func principalName(ctx context.Context) string {
+	if p, ok := PrincipalFromContext(ctx); ok {
+		return p.Name
+	}
+	return ""
+}
+
+// PrincipalOf returns the principal of the call the file system got
+// fctx for. It's only known while the call runs.
+func PrincipalOf(fctx *fuse.Context) (*Principal, bool) {
+	if c, ok := callOf(fctx); ok && c.principal != nil {
+		return c.principal, true
+	}
+	return nil, false
+}
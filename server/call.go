@@ -0,0 +1,126 @@
+package server
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hanwen/go-fuse/fuse"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// clientIDKey is the metadata key under which clients send an id of their
+// own, so the changes they make aren't sent back to them by Watch. The
+// grpcfs package uses the same key.
+const clientIDKey = "grfuse-client-id"
+
+// call is what is known about a running call: its context, its
+// principal, if it was authenticated, its origin, and the file system
+// contexts created for it.
+type call struct {
+	ctx       context.Context
+	principal *Principal
+	origin    string
+
+	mu       sync.Mutex
+	contexts []*fuse.Context
+}
+
+type callKey struct{}
+
+// calls maps the file system contexts of running calls to their calls.
+var calls sync.Map
+
+// opKey is the context key of the operation a call makes.
+type opKey struct{}
+
+// callOp returns the operation of the call made with ctx.
+func callOp(ctx context.Context) string {
+	op, _ := ctx.Value(opKey{}).(string)
+	return op
+}
+
+// startCall returns the context of the call op on name, made with ctx, and
+// keeps track of it. The returned function ends the call, logging it if
+// debugging, and is deferred by every call.
+func (s *fuseServer) startCall(ctx context.Context, op, name string) (context.Context, func()) {
+	ctx, forget := newCall(context.WithValue(ctx, opKey{}, op))
+	if !s.debugging() {
+		return ctx, forget
+	}
+	id := requestID(ctx)
+	start := time.Now()
+	return ctx, func() {
+		forget()
+		log.Printf("[%s] %s %q: done in %v", id, op, name, time.Since(start))
+	}
+}
+
+// newCall returns a context for a call made with ctx. The returned
+// function forgets about the call once it's done.
+func newCall(ctx context.Context) (context.Context, func()) {
+	c := &call{ctx: ctx, origin: origin(ctx)}
+	if p, ok := PrincipalFromContext(ctx); ok {
+		c.principal = p
+	}
+	return context.WithValue(ctx, callKey{}, c), func() {
+		c.mu.Lock()
+		for _, fctx := range c.contexts {
+			calls.Delete(fctx)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// addContext makes the call of ctx known for fctx.
+func addContext(ctx context.Context, fctx *fuse.Context) {
+	c, ok := ctx.Value(callKey{}).(*call)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	c.contexts = append(c.contexts, fctx)
+	c.mu.Unlock()
+	calls.Store(fctx, c)
+}
+
+// CallContext returns the context of the call the file system got fctx
+// for, while it runs, e.g. to continue its trace.
+func CallContext(fctx *fuse.Context) (context.Context, bool) {
+	if c, ok := callOf(fctx); ok {
+		return c.ctx, true
+	}
+	return nil, false
+}
+
+// callOf returns the call the file system got fctx for, while it runs.
+func callOf(fctx *fuse.Context) (*call, bool) {
+	c, ok := calls.Load(fctx)
+	if !ok {
+		return nil, false
+	}
+	return c.(*call), true
+}
+
+// origin returns the client making a call with ctx, as the id it sent
+// along with the address it connected from, so it can't claim the id of
+// a client on another connection. It's "" for clients without an id.
+func origin(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	p, ok := peer.FromContext(ctx)
+	if len(md[clientIDKey]) == 0 || !ok || p.Addr == nil {
+		return ""
+	}
+	return md[clientIDKey][0] + "@" + p.Addr.String()
+}
+
+// originOf returns the origin of the call the file system got fctx for,
+// or "" if it isn't known.
+func originOf(fctx *fuse.Context) string {
+	if c, ok := callOf(fctx); ok {
+		return c.origin
+	}
+	return ""
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+// requestIDKey is the metadata key of the request id which clients send
+// while debugging. grpcfs uses the same key.
+const requestIDKey = "grfuse-request-id"
+
+func (s *fuseServer) debugging() bool {
+	return atomic.LoadInt32(&s.debug) != 0
+}
+
+// maxRequestIDLen is the longest request id logged, longer ones are cut.
+const maxRequestIDLen = 64
+
+// requestID returns the request id sent by the client, or "-". It comes
+// from the client, so it's cut short and quoted unless it's plain text.
+func requestID(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md[requestIDKey]) == 0 {
+		return "-"
+	}
+	id := md[requestIDKey][0]
+	if len(id) > maxRequestIDLen {
+		id = id[:maxRequestIDLen]
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' || id[i] == '"' {
+			return fmt.Sprintf("%q", id)
+		}
+	}
+	return id
+}
+
+// mayDebug reports whether the client making the call of ctx may turn
+// debugging on and off.
+func (s *fuseServer) mayDebug(ctx context.Context) bool {
+	p, authenticated := PrincipalFromContext(ctx)
+	for _, name := range s.debugPrincipals {
+		if name == "*" || authenticated && name == p.Name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/nodefs"
+)
+
+// defaultHandleTimeout is how long handles are kept unused by default.
+const defaultHandleTimeout = time.Hour
+
+// openFile is a file opened on behalf of a client.
+type openFile struct {
+	nodefs.File
+	// name is the path the file was opened with.
+	name string
+	// owner is the name of the principal which opened the file, the
+	// only one which may use the handle.
+	owner string
+	// used is when the handle was last used, in nanoseconds since the
+	// epoch, accessed atomically.
+	used int64
+}
+
+// touch marks the file as used, e.g. while streaming from or to it.
+func (f *openFile) touch() {
+	atomic.StoreInt64(&f.used, time.Now().UnixNano())
+}
+
+// handleTable keeps files opened on behalf of clients, keyed by the handle
+// which is sent back in pb.File.
+//
+// Handles are random, so clients can't guess the handles of others, and
+// handles given out by a previous run of the server aren't mistaken for
+// new ones.
+//
+// Clients which go away without releasing their handles would keep the
+// files open forever, so handles unused for longer than the timeout are
+// released. Clients open the files again if they come back.
+type handleTable struct {
+	mu      sync.Mutex
+	files   map[uint64]*openFile
+	timeout time.Duration
+	swept   time.Time
+}
+
+func newHandleTable(timeout time.Duration) *handleTable {
+	return &handleTable{
+		files:   make(map[uint64]*openFile),
+		timeout: timeout,
+		swept:   time.Now(),
+	}
+}
+
+// add registers f, opened as name by owner, and returns its handle.
+// Handle 0 is never used.
+func (t *handleTable) add(name, owner string, f nodefs.File) uint64 {
+	t.expire()
+	of := &openFile{File: f, name: name, owner: owner}
+	of.touch()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for {
+		h := randomHandle()
+		if _, ok := t.files[h]; h != 0 && !ok {
+			t.files[h] = of
+			return h
+		}
+	}
+}
+
+// randomHandle returns a handle which can't be predicted from the ones
+// given out before.
+func randomHandle() uint64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return binary.LittleEndian.Uint64(b[:])
+}
+
+// get returns the file of h used by owner, and marks it as used. It
+// fails with ESTALE if h is unknown, and with EBADF if it was opened by
+// another principal.
+func (t *handleTable) get(h uint64, owner string) (*openFile, fuse.Status) {
+	t.mu.Lock()
+	f, ok := t.files[h]
+	t.mu.Unlock()
+	if !ok {
+		return nil, fuse.Status(syscall.ESTALE)
+	}
+	if f.owner != owner {
+		return nil, fuse.EBADF
+	}
+	f.touch()
+	return f, fuse.OK
+}
+
+// expire releases the files unused for longer than the timeout. Files are
+// only opened through add, so checking there at most every half timeout
+// is enough to bound the number of files kept open.
+func (t *handleTable) expire() {
+	if t.timeout <= 0 {
+		return
+	}
+	now := time.Now()
+	var expired []*openFile
+	t.mu.Lock()
+	if now.Sub(t.swept) >= t.timeout/2 {
+		t.swept = now
+		for h, f := range t.files {
+			if now.Sub(time.Unix(0, atomic.LoadInt64(&f.used))) >= t.timeout {
+				delete(t.files, h)
+				expired = append(expired, f)
+			}
+		}
+	}
+	t.mu.Unlock()
+	for _, f := range expired {
+		f.Release()
+	}
+}
+
+// remove forgets the handle released by owner and returns the file it
+// referred to. It fails like get.
+func (t *handleTable) remove(h uint64, owner string) (*openFile, fuse.Status) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f, ok := t.files[h]
+	if !ok {
+		return nil, fuse.Status(syscall.ESTALE)
+	}
+	if f.owner != owner {
+		return nil, fuse.EBADF
+	}
+	delete(t.files, h)
+	return f, fuse.OK
+}
+
+// name returns the name h was opened with, or "" if it is unknown.
+func (t *handleTable) name(h uint64) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if f, ok := t.files[h]; ok {
+		return f.name
+	}
+	return ""
+}
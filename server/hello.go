@@ -0,0 +1,43 @@
+package server
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/LK4D4/grfuse/pb"
+	"golang.org/x/net/context"
+)
+
+// protocolVersion is the version of the protocol spoken by the server.
+// Additions are announced as features, the version only changes if old
+// clients can't talk to the server anymore.
+const protocolVersion = 1
+
+// baseFeatures lists what every server implements.
+var baseFeatures = []pb.Feature{
+	pb.Feature_Handles,
+	pb.Feature_StaleHandles,
+	pb.Feature_StreamRead,
+	pb.Feature_StreamWrite,
+	pb.Feature_StreamDir,
+	pb.Feature_DirPlus,
+	pb.Feature_WatchChanges,
+}
+
+// newSession returns a random session id, which is never zero.
+func newSession() uint64 {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return uint64(r.Int63()) | 1
+}
+
+func (s *fuseServer) Hello(ctx context.Context, r *pb.HelloRequest) (*pb.HelloResponse, error) {
+	ctx, done := s.startCall(ctx, "Hello", "")
+	defer done()
+	limits := s.limits
+	return &pb.HelloResponse{
+		Version:  protocolVersion,
+		Features: s.features,
+		Limits:   &limits,
+		Session:  s.session,
+	}, nil
+}
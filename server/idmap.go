@@ -0,0 +1,147 @@
+package server
+
+// nobody is the id of the anonymous user and group by default.
+const nobody = 65534
+
+// IDMapping maps Count ids starting at Client to ids starting at Server,
+// like a line of /proc/PID/uid_map does for user namespaces.
+type IDMapping struct {
+	Client, Server, Count uint32
+}
+
+// IDMap translates the uids and gids clients act as to those of the
+// server, and back for the owners of files. Without mappings, ids are the
+// same on both sides. Auth checks the ids clients send, before they are
+// mapped.
+type IDMap struct {
+	// RootSquash makes clients acting as root act as the anonymous
+	// user and group instead.
+	RootSquash bool
+	// AllSquash makes all clients act as the anonymous user and group.
+	AllSquash bool
+	// AnonUID and AnonGID are the anonymous user and group, which also
+	// stand in for ids without a mapping that clients act as, and which
+	// calls without an owner act as. Chown to ids without a mapping or
+	// squashed ones fails with EINVAL. Zero means 65534.
+	AnonUID, AnonGID uint32
+	UIDs, GIDs       []IDMapping
+}
+
+func mapID(maps []IDMapping, id uint32, toServer bool) (uint32, bool) {
+	if len(maps) == 0 {
+		return id, true
+	}
+	for _, m := range maps {
+		from, to := m.Server, m.Client
+		if toServer {
+			from, to = m.Client, m.Server
+		}
+		if id >= from && id-from < m.Count {
+			return to + id - from, true
+		}
+	}
+	return 0, false
+}
+
+func orNobody(id uint32) uint32 {
+	if id == 0 {
+		return nobody
+	}
+	return id
+}
+
+func (m *IDMap) toServer(maps []IDMapping, id, anon uint32) uint32 {
+	if id == unchangedID {
+		return id
+	}
+	if m.AllSquash || m.RootSquash && id == 0 {
+		return orNobody(anon)
+	}
+	if sid, ok := mapID(maps, id, true); ok {
+		return sid
+	}
+	return orNobody(anon)
+}
+
+func (m *IDMap) toClient(maps []IDMapping, id, anon uint32) uint32 {
+	if cid, ok := mapID(maps, id, false); ok {
+		return cid
+	}
+	return orNobody(anon)
+}
+
+// serverUID and serverGID map ids of clients to the server's.
+func (m *IDMap) serverUID(uid uint32) uint32 {
+	if m == nil {
+		return uid
+	}
+	return m.toServer(m.UIDs, uid, m.AnonUID)
+}
+
+func (m *IDMap) serverGID(gid uint32) uint32 {
+	if m == nil {
+		return gid
+	}
+	return m.toServer(m.GIDs, gid, m.AnonGID)
+}
+
+// chownID maps an id a client gives a file to with Chown to the server's.
+// Unlike the ids clients act as, ids without a mapping or squashed ones
+// don't become the anonymous one, which would give the file away, but
+// can't be used.
+func (m *IDMap) chownID(maps []IDMapping, id uint32) (uint32, bool) {
+	if id == unchangedID {
+		return id, true
+	}
+	if m.AllSquash || m.RootSquash && id == 0 {
+		return 0, false
+	}
+	return mapID(maps, id, true)
+}
+
+// chownUID and chownGID map the ids given to Chown, reporting whether they
+// have a mapping.
+func (m *IDMap) chownUID(uid uint32) (uint32, bool) {
+	if m == nil {
+		return uid, true
+	}
+	return m.chownID(m.UIDs, uid)
+}
+
+func (m *IDMap) chownGID(gid uint32) (uint32, bool) {
+	if m == nil {
+		return gid, true
+	}
+	return m.chownID(m.GIDs, gid)
+}
+
+// anonUID and anonGID are the server's ids of the anonymous user and
+// group, which calls without an owner act as.
+func (m *IDMap) anonUID() uint32 {
+	if m == nil {
+		return nobody
+	}
+	return orNobody(m.AnonUID)
+}
+
+func (m *IDMap) anonGID() uint32 {
+	if m == nil {
+		return nobody
+	}
+	return orNobody(m.AnonGID)
+}
+
+// clientUID and clientGID map ids of the server to the clients'.
+func (m *IDMap) clientUID(uid uint32) uint32 {
+	if m == nil {
+		return uid
+	}
+	return m.toClient(m.UIDs, uid, m.AnonUID)
+}
+
+func (m *IDMap) clientGID(gid uint32) uint32 {
+	if m == nil {
+		return gid
+	}
+	return m.toClient(m.GIDs, gid, m.AnonGID)
+}
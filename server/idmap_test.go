@@ -0,0 +1,127 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/LK4D4/grfuse/pb"
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+	"golang.org/x/net/context"
+)
+
+var testMaps = []IDMapping{
+	{Client: 1000, Server: 5000, Count: 100},
+	{Client: 0, Server: 100000, Count: 1},
+}
+
+func TestToServer(t *testing.T) {
+	for _, c := range []struct {
+		m        IDMap
+		id, want uint32
+	}{
+		{IDMap{}, 1005, 5005},
+		{IDMap{}, 0, 100000},
+		{IDMap{}, unchangedID, unchangedID},
+		// Ids without a mapping act as the anonymous one.
+		{IDMap{}, 2000, nobody},
+		{IDMap{AnonUID: 99}, 2000, 99},
+		{IDMap{RootSquash: true}, 0, nobody},
+		{IDMap{RootSquash: true}, 1005, 5005},
+		{IDMap{AllSquash: true}, 1005, nobody},
+		{IDMap{AllSquash: true}, unchangedID, unchangedID},
+	} {
+		if got := c.m.toServer(testMaps, c.id, c.m.AnonUID); got != c.want {
+			t.Errorf("%+v maps client id %d to %d, want %d", c.m, c.id, got, c.want)
+		}
+	}
+	if got := (&IDMap{}).toServer(nil, 2000, 0); got != 2000 {
+		t.Errorf("without mappings, client id 2000 is %d on the server", got)
+	}
+}
+
+func TestToClient(t *testing.T) {
+	for _, c := range []struct {
+		m        IDMap
+		id, want uint32
+	}{
+		{IDMap{}, 5005, 1005},
+		{IDMap{}, 100000, 0},
+		{IDMap{}, 0, nobody},
+		{IDMap{AnonUID: 99}, 7, 99},
+	} {
+		if got := c.m.toClient(testMaps, c.id, c.m.AnonUID); got != c.want {
+			t.Errorf("%+v maps server id %d to %d, want %d", c.m, c.id, got, c.want)
+		}
+	}
+}
+
+func TestChownID(t *testing.T) {
+	for _, c := range []struct {
+		m    IDMap
+		id   uint32
+		want uint32
+		ok   bool
+	}{
+		{IDMap{}, 1005, 5005, true},
+		{IDMap{}, unchangedID, unchangedID, true},
+		// Unlike the ids clients act as, these don't become the
+		// anonymous id.
+		{IDMap{}, 2000, 0, false},
+		{IDMap{RootSquash: true}, 0, 0, false},
+		{IDMap{RootSquash: true}, 1005, 5005, true},
+		{IDMap{AllSquash: true}, 1005, 0, false},
+		{IDMap{AllSquash: true}, unchangedID, unchangedID, true},
+	} {
+		got, ok := c.m.chownID(testMaps, c.id)
+		if got != c.want || ok != c.ok {
+			t.Errorf("%+v maps chown to %d to %d, %v, want %d, %v", c.m, c.id, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+// ownerFs reports fileOwner as owning every file, and records the owner
+// it's called as.
+type ownerFs struct {
+	pathfs.FileSystem
+	fileOwner fuse.Owner
+	ctxOwner  fuse.Owner
+}
+
+func (fs *ownerFs) GetAttr(name string, context *fuse.Context) (*fuse.Attr, fuse.Status) {
+	fs.ctxOwner = context.Owner
+	return &fuse.Attr{Mode: fuse.S_IFREG | 0644, Owner: fs.fileOwner}, fuse.OK
+}
+
+func TestIDMap(t *testing.T) {
+	backend := &ownerFs{FileSystem: pathfs.NewDefaultFileSystem()}
+	s := newTestServer(t, backend, Options{IDMap: &IDMap{
+		RootSquash: true,
+		UIDs:       []IDMapping{{Client: 1000, Server: 5000, Count: 100}},
+		GIDs:       []IDMapping{{Client: 100, Server: 500, Count: 1}},
+	}})
+	for _, c := range []struct {
+		client, server, file, seen fuse.Owner
+	}{
+		{fuse.Owner{Uid: 1005, Gid: 100}, fuse.Owner{Uid: 5005, Gid: 500}, fuse.Owner{Uid: 5001, Gid: 500}, fuse.Owner{Uid: 1001, Gid: 100}},
+		{fuse.Owner{Uid: 0, Gid: 0}, fuse.Owner{Uid: nobody, Gid: nobody}, fuse.Owner{Uid: 0, Gid: 0}, fuse.Owner{Uid: nobody, Gid: nobody}},
+		{fuse.Owner{Uid: 2000, Gid: 101}, fuse.Owner{Uid: nobody, Gid: nobody}, fuse.Owner{Uid: 5099, Gid: 501}, fuse.Owner{Uid: 1099, Gid: nobody}},
+	} {
+		backend.fileOwner = c.file
+		resp, err := s.GetAttr(context.Background(), &pb.GetAttrRequest{
+			Name:    "file",
+			Context: &pb.Context{Owner: &pb.Owner{Uid: c.client.Uid, Gid: c.client.Gid}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Status.Code != fuse.OK {
+			t.Fatal(resp.Status.Code)
+		}
+		if backend.ctxOwner != c.server {
+			t.Errorf("client %v acts as %v on the server, want %v", c.client, backend.ctxOwner, c.server)
+		}
+		if seen := (fuse.Owner{Uid: resp.Attr.Owner.Uid, Gid: resp.Attr.Owner.Gid}); seen != c.seen {
+			t.Errorf("file owned by %v on the server is owned by %v on the client, want %v", c.file, seen, c.seen)
+		}
+	}
+}
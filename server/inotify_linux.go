@@ -0,0 +1,308 @@
+package server
+
+import (
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/LK4D4/grfuse/pb"
+)
+
+const (
+	inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY |
+		syscall.IN_ATTRIB | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+		syscall.IN_ONLYDIR | syscall.IN_EXCL_UNLINK
+	// coalesceDelay is how long events are collected before they are
+	// sent, so a burst of changes to a file becomes a single event.
+	coalesceDelay = 50 * time.Millisecond
+	// maxPending is the number of distinct events collected at once.
+	// More are replaced by a single Rescan.
+	maxPending = 4096
+)
+
+// InotifySource is a ChangeSource which watches a local directory tree,
+// e.g. the root of a loopback file system, with inotify.
+type InotifySource struct {
+	root string
+	fd   int
+	file *os.File
+
+	// dirs and wds map watched directories, relative to root, to their
+	// watch descriptors and back. They are only used by the goroutine
+	// reading events once it is started.
+	dirs map[string]int32
+	wds  map[int32]string
+	// moved is the name of the file last moved away, with the cookie
+	// of that move, until it's known whether it was a rename.
+	moved       string
+	movedCookie uint32
+	movedDir    bool
+
+	raw       chan *pb.WatchEvent
+	events    chan *pb.WatchEvent
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewInotifySource starts watching root and all directories below it.
+// The source must be closed to release the inotify instance.
+func NewInotifySource(root string) (*InotifySource, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	s := &InotifySource{
+		root:   filepath.Clean(root),
+		fd:     fd,
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		dirs:   make(map[string]int32),
+		wds:    make(map[int32]string),
+		raw:    make(chan *pb.WatchEvent, watchBuffer),
+		events: make(chan *pb.WatchEvent, watchBuffer),
+		done:   make(chan struct{}),
+	}
+	if err := s.addTree("", false); err != nil {
+		s.file.Close()
+		return nil, err
+	}
+	go s.readEvents()
+	go s.coalesce()
+	return s, nil
+}
+
+func (s *InotifySource) Events() <-chan *pb.WatchEvent {
+	return s.events
+}
+
+// Close stops watching. The events channel is closed once pending events
+// are delivered.
+func (s *InotifySource) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		err = s.file.Close()
+	})
+	return err
+}
+
+// addTree watches dir and the directories below it. If created is set,
+// a Create event is sent for everything found, as those entries might
+// have been created before the watch was in place.
+func (s *InotifySource) addTree(dir string, created bool) error {
+	top := filepath.Join(s.root, dir)
+	return filepath.Walk(top, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if p == top {
+				return err
+			}
+			// Removed while walking.
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			rel = ""
+		}
+		if created && p != top {
+			s.send(&pb.WatchEvent{Op: pb.WatchOp_Create, Name: rel})
+		}
+		if !fi.IsDir() {
+			return nil
+		}
+		wd, err := syscall.InotifyAddWatch(s.fd, p, inotifyMask)
+		if err != nil {
+			if p == top {
+				return os.NewSyscallError("inotify_add_watch", err)
+			}
+			// Changes below p are missed, most likely because
+			// fs.inotify.max_user_watches was reached.
+			log.Printf("Error watching %s: %v", p, err)
+			return filepath.SkipDir
+		}
+		s.dirs[rel] = int32(wd)
+		s.wds[int32(wd)] = rel
+		return nil
+	})
+}
+
+// removeTree stops watching dir and the directories below it.
+func (s *InotifySource) removeTree(dir string) {
+	for d, wd := range s.dirs {
+		if below(d, dir) {
+			syscall.InotifyRmWatch(s.fd, uint32(wd))
+			delete(s.dirs, d)
+			delete(s.wds, wd)
+		}
+	}
+}
+
+// renameTree updates the names of the watched directories after dir was
+// renamed to newDir.
+func (s *InotifySource) renameTree(dir, newDir string) {
+	moved := make(map[string]int32)
+	for d, wd := range s.dirs {
+		if below(d, dir) {
+			moved[newDir+d[len(dir):]] = wd
+			delete(s.dirs, d)
+		}
+	}
+	for d, wd := range moved {
+		s.dirs[d] = wd
+		s.wds[wd] = d
+	}
+}
+
+func (s *InotifySource) send(e *pb.WatchEvent) {
+	select {
+	case s.raw <- e:
+	case <-s.done:
+	}
+}
+
+func (s *InotifySource) readEvents() {
+	defer close(s.raw)
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := s.file.Read(buf)
+		if err != nil {
+			select {
+			case <-s.done:
+			default:
+				log.Printf("Error reading inotify events for %s: %v", s.root, err)
+			}
+			return
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			off += syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[off:off+int(ev.Len)]), "\x00")
+			off += int(ev.Len)
+			s.handle(ev.Wd, ev.Mask, ev.Cookie, name)
+		}
+		// Both halves of a rename are read together.
+		s.flushMove()
+	}
+}
+
+func (s *InotifySource) flushMove() {
+	if s.moved == "" {
+		return
+	}
+	s.send(&pb.WatchEvent{Op: pb.WatchOp_Delete, Name: s.moved})
+	if s.movedDir {
+		s.removeTree(s.moved)
+	}
+	s.moved = ""
+}
+
+func (s *InotifySource) handle(wd int32, mask, cookie uint32, name string) {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		s.send(&pb.WatchEvent{Op: pb.WatchOp_Rescan})
+		return
+	}
+	dir, ok := s.wds[wd]
+	if !ok {
+		return
+	}
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(s.wds, wd)
+		if s.dirs[dir] == wd {
+			delete(s.dirs, dir)
+		}
+		return
+	}
+	rel := path.Join(dir, name)
+	isDir := mask&syscall.IN_ISDIR != 0
+	if s.moved != "" && (mask&syscall.IN_MOVED_TO == 0 || cookie != s.movedCookie) {
+		s.flushMove()
+	}
+	switch {
+	case mask&syscall.IN_MOVED_FROM != 0:
+		s.moved = rel
+		s.movedCookie = cookie
+		s.movedDir = isDir
+	case mask&syscall.IN_MOVED_TO != 0 && s.moved != "":
+		s.send(&pb.WatchEvent{Op: pb.WatchOp_Rename, Name: s.moved, NewName: rel})
+		if isDir {
+			s.renameTree(s.moved, rel)
+		}
+		s.moved = ""
+	case mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+		s.send(&pb.WatchEvent{Op: pb.WatchOp_Create, Name: rel})
+		if isDir {
+			if err := s.addTree(rel, true); err != nil {
+				log.Printf("Error watching %s: %v", rel, err)
+			}
+		}
+	case mask&syscall.IN_DELETE != 0:
+		s.send(&pb.WatchEvent{Op: pb.WatchOp_Delete, Name: rel})
+	case mask&(syscall.IN_MODIFY|syscall.IN_ATTRIB) != 0:
+		s.send(&pb.WatchEvent{Op: pb.WatchOp_Modify, Name: rel})
+	}
+}
+
+type eventKey struct {
+	op            pb.WatchOp
+	name, newName string
+}
+
+// coalesce collects events for coalesceDelay, drops duplicates and passes
+// the rest on. Of duplicate events only the last is kept, so the order of
+// the events still leads to the final state.
+func (s *InotifySource) coalesce() {
+	defer close(s.events)
+	var (
+		pending []*pb.WatchEvent
+		seen    = make(map[eventKey]int)
+		timer   <-chan time.Time
+	)
+	flush := func() {
+		if len(seen) > maxPending {
+			pending = []*pb.WatchEvent{{Op: pb.WatchOp_Rescan}}
+		}
+		for _, e := range pending {
+			if e == nil {
+				continue
+			}
+			select {
+			case s.events <- e:
+			case <-s.done:
+			}
+		}
+		pending = nil
+		seen = make(map[eventKey]int)
+		timer = nil
+	}
+	for {
+		select {
+		case e, ok := <-s.raw:
+			if !ok {
+				flush()
+				return
+			}
+			if len(seen) > maxPending {
+				continue
+			}
+			k := eventKey{e.Op, e.Name, e.NewName}
+			if i, ok := seen[k]; ok {
+				pending[i] = nil
+			}
+			seen[k] = len(pending)
+			pending = append(pending, e)
+			if timer == nil {
+				timer = time.After(coalesceDelay)
+			}
+		case <-timer:
+			flush()
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/LK4D4/grfuse/pb"
+)
+
+func TestCoalesceKeepsLast(t *testing.T) {
+	s := &InotifySource{
+		raw:    make(chan *pb.WatchEvent, watchBuffer),
+		events: make(chan *pb.WatchEvent, watchBuffer),
+		done:   make(chan struct{}),
+	}
+	go s.coalesce()
+	for _, e := range []*pb.WatchEvent{
+		{Op: pb.WatchOp_Create, Name: "a"},
+		{Op: pb.WatchOp_Modify, Name: "a"},
+		{Op: pb.WatchOp_Delete, Name: "a"},
+		{Op: pb.WatchOp_Create, Name: "a"},
+		{Op: pb.WatchOp_Modify, Name: "a"},
+	} {
+		s.raw <- e
+	}
+	close(s.raw)
+	var got []*pb.WatchEvent
+	for e := range s.events {
+		got = append(got, e)
+	}
+	want := []*pb.WatchEvent{
+		{Op: pb.WatchOp_Delete, Name: "a"},
+		{Op: pb.WatchOp_Create, Name: "a"},
+		{Op: pb.WatchOp_Modify, Name: "a"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Got events %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].Op != want[i].Op || got[i].Name != want[i].Name {
+			t.Fatalf("Got events %v, want %v", got, want)
+		}
+	}
+}
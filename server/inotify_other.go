@@ -0,0 +1,25 @@
+//go:build !linux
+// +build !linux
+
+package server
+
+import (
+	"errors"
+
+	"github.com/LK4D4/grfuse/pb"
+)
+
+// InotifySource is only available on Linux.
+type InotifySource struct{}
+
+func NewInotifySource(root string) (*InotifySource, error) {
+	return nil, errors.New("inotify is only supported on Linux")
+}
+
+func (s *InotifySource) Events() <-chan *pb.WatchEvent {
+	return nil
+}
+
+func (s *InotifySource) Close() error {
+	return nil
+}
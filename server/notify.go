@@ -0,0 +1,163 @@
+package server
+
+import (
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/LK4D4/grfuse/pb"
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/nodefs"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+)
+
+// notifyingFileSystem publishes the changes successfully made through it
+// to Watch streams, along with the origin of the calls making them. With
+// a ChangeSource, it only tells the hub which calls made the changes the
+// source is about to report.
+type notifyingFileSystem struct {
+	pathfs.FileSystem
+	watches *watchHub
+}
+
+func (fs *notifyingFileSystem) notify(code fuse.Status, origin string, op pb.WatchOp, name, newName string) fuse.Status {
+	if code == fuse.OK {
+		fs.watches.made(&pb.WatchEvent{
+			Op:      op,
+			Name:    name,
+			NewName: newName,
+		}, origin)
+	}
+	return code
+}
+
+func (fs *notifyingFileSystem) Chmod(name string, mode uint32, context *fuse.Context) fuse.Status {
+	return fs.notify(fs.FileSystem.Chmod(name, mode, context), originOf(context), pb.WatchOp_Modify, name, "")
+}
+
+func (fs *notifyingFileSystem) Chown(name string, uid uint32, gid uint32, context *fuse.Context) fuse.Status {
+	return fs.notify(fs.FileSystem.Chown(name, uid, gid, context), originOf(context), pb.WatchOp_Modify, name, "")
+}
+
+func (fs *notifyingFileSystem) Utimens(name string, atime *time.Time, mtime *time.Time, context *fuse.Context) fuse.Status {
+	return fs.notify(fs.FileSystem.Utimens(name, atime, mtime, context), originOf(context), pb.WatchOp_Modify, name, "")
+}
+
+func (fs *notifyingFileSystem) Truncate(name string, size uint64, context *fuse.Context) fuse.Status {
+	return fs.notify(fs.FileSystem.Truncate(name, size, context), originOf(context), pb.WatchOp_Modify, name, "")
+}
+
+func (fs *notifyingFileSystem) Link(oldName string, newName string, context *fuse.Context) fuse.Status {
+	code := fs.notify(fs.FileSystem.Link(oldName, newName, context), originOf(context), pb.WatchOp_Create, newName, "")
+	// The link count of oldName changed.
+	return fs.notify(code, originOf(context), pb.WatchOp_Modify, oldName, "")
+}
+
+func (fs *notifyingFileSystem) Mkdir(name string, mode uint32, context *fuse.Context) fuse.Status {
+	return fs.notify(fs.FileSystem.Mkdir(name, mode, context), originOf(context), pb.WatchOp_Create, name, "")
+}
+
+func (fs *notifyingFileSystem) Mknod(name string, mode uint32, dev uint32, context *fuse.Context) fuse.Status {
+	return fs.notify(fs.FileSystem.Mknod(name, mode, dev, context), originOf(context), pb.WatchOp_Create, name, "")
+}
+
+func (fs *notifyingFileSystem) Rename(oldName string, newName string, context *fuse.Context) fuse.Status {
+	return fs.notify(fs.FileSystem.Rename(oldName, newName, context), originOf(context), pb.WatchOp_Rename, oldName, newName)
+}
+
+func (fs *notifyingFileSystem) Rmdir(name string, context *fuse.Context) fuse.Status {
+	return fs.notify(fs.FileSystem.Rmdir(name, context), originOf(context), pb.WatchOp_Delete, name, "")
+}
+
+func (fs *notifyingFileSystem) Unlink(name string, context *fuse.Context) fuse.Status {
+	return fs.notify(fs.FileSystem.Unlink(name, context), originOf(context), pb.WatchOp_Delete, name, "")
+}
+
+func (fs *notifyingFileSystem) RemoveXAttr(name string, attr string, context *fuse.Context) fuse.Status {
+	return fs.notify(fs.FileSystem.RemoveXAttr(name, attr, context), originOf(context), pb.WatchOp_Modify, name, "")
+}
+
+func (fs *notifyingFileSystem) SetXAttr(name string, attr string, data []byte, flags int, context *fuse.Context) fuse.Status {
+	return fs.notify(fs.FileSystem.SetXAttr(name, attr, data, flags, context), originOf(context), pb.WatchOp_Modify, name, "")
+}
+
+func (fs *notifyingFileSystem) Symlink(value string, linkName string, context *fuse.Context) fuse.Status {
+	return fs.notify(fs.FileSystem.Symlink(value, linkName, context), originOf(context), pb.WatchOp_Create, linkName, "")
+}
+
+func (fs *notifyingFileSystem) Open(name string, flags uint32, context *fuse.Context) (nodefs.File, fuse.Status) {
+	f, code := fs.FileSystem.Open(name, flags, context)
+	if code != fuse.OK || flags&fuse.O_ANYWRITE == 0 {
+		return f, code
+	}
+	if flags&syscall.O_TRUNC != 0 {
+		fs.notify(code, originOf(context), pb.WatchOp_Modify, name, "")
+	}
+	return &notifyingFile{File: f, fs: fs, name: name, origin: originOf(context)}, code
+}
+
+func (fs *notifyingFileSystem) Create(name string, flags uint32, mode uint32, context *fuse.Context) (nodefs.File, fuse.Status) {
+	f, code := fs.FileSystem.Create(name, flags, mode, context)
+	if code != fuse.OK {
+		return f, code
+	}
+	fs.notify(code, originOf(context), pb.WatchOp_Create, name, "")
+	return &notifyingFile{File: f, fs: fs, name: name, origin: originOf(context)}, code
+}
+
+// notifyingFile publishes a Modify event once data written to it is
+// flushed, rather than on every write. Handles aren't shared between
+// clients, so its changes are made by the client which opened it.
+type notifyingFile struct {
+	nodefs.File
+	fs     *notifyingFileSystem
+	name   string
+	origin string
+	// dirty is set to 1 by writes.
+	dirty uint32
+}
+
+func (f *notifyingFile) InnerFile() nodefs.File {
+	return f.File
+}
+
+func (f *notifyingFile) modified() {
+	if atomic.SwapUint32(&f.dirty, 0) == 1 {
+		f.fs.notify(fuse.OK, f.origin, pb.WatchOp_Modify, f.name, "")
+	}
+}
+
+func (f *notifyingFile) Write(data []byte, off int64) (uint32, fuse.Status) {
+	n, code := f.File.Write(data, off)
+	if n > 0 {
+		atomic.StoreUint32(&f.dirty, 1)
+		// A source reports writes as they happen, not once flushed.
+		f.fs.watches.expect(f.origin, f.name)
+	}
+	return n, code
+}
+
+func (f *notifyingFile) Flush() fuse.Status {
+	code := f.File.Flush()
+	f.modified()
+	return code
+}
+
+func (f *notifyingFile) Fsync(flags int) fuse.Status {
+	code := f.File.Fsync(flags)
+	f.modified()
+	return code
+}
+
+func (f *notifyingFile) Release() {
+	f.File.Release()
+	f.modified()
+}
+
+func (f *notifyingFile) Truncate(size uint64) fuse.Status {
+	return f.fs.notify(f.File.Truncate(size), f.origin, pb.WatchOp_Modify, f.name, "")
+}
+
+func (f *notifyingFile) Allocate(off uint64, size uint64, mode uint32) fuse.Status {
+	return f.fs.notify(f.File.Allocate(off, size, mode), f.origin, pb.WatchOp_Modify, f.name, "")
+}
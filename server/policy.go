@@ -0,0 +1,391 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/nodefs"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+	"golang.org/x/net/context"
+)
+
+// OpClass groups the calls a policy rule applies to.
+type OpClass string
+
+const (
+	// ReadOps are calls which only look at the file system, including
+	// opening files for reading.
+	ReadOps OpClass = "read"
+	// WriteOps are calls which modify it, including opening files for
+	// writing.
+	WriteOps OpClass = "write"
+)
+
+// Rule allows or denies calls of principals on paths.
+type Rule struct {
+	// Principal is the name of the principal the rule applies to, "*"
+	// for all. Calls of unauthenticated clients have the principal "".
+	Principal string `json:"principal"`
+	// Path is a pattern as for path.Match, matched against the path
+	// relative to the export's root. A pattern ending in "/**" also
+	// matches everything below the directory, "**" everything. Calls
+	// have to be allowed both on the path they give and on the one it
+	// leads to through symlinks.
+	Path string `json:"path"`
+	// Ops are the classes of calls the rule applies to, all if empty.
+	Ops   []OpClass `json:"ops"`
+	Allow bool      `json:"allow"`
+}
+
+func (r *Rule) matches(principal, name string, op OpClass) bool {
+	if r.Principal != "*" && r.Principal != principal {
+		return false
+	}
+	if len(r.Ops) > 0 {
+		found := false
+		for _, o := range r.Ops {
+			if o == op {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return matchPath(r.Path, name)
+}
+
+func matchPath(pattern, name string) bool {
+	if pattern == "**" {
+		return true
+	}
+	if strings.HasSuffix(pattern, "/**") {
+		dir := strings.TrimSuffix(pattern, "/**")
+		if ok, _ := path.Match(dir, name); ok {
+			return true
+		}
+		for d := path.Dir(name); d != "." && d != "/"; d = path.Dir(d) {
+			if ok, _ := path.Match(dir, d); ok {
+				return true
+			}
+		}
+		return false
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// policyFile is the format of policy files:
+//
+//	{
+//		"default": "deny",
+//		"rules": [
+//			{"principal": "ci", "path": "cache/**", "ops": ["read", "write"], "allow": true},
+//			{"principal": "*", "path": "**", "ops": ["read"], "allow": true}
+//		]
+//	}
+type policyFile struct {
+	// Default is "allow" or "deny", for calls no rule matches. Deny
+	// if empty.
+	Default string `json:"default"`
+	Rules   []Rule `json:"rules"`
+}
+
+// Policy decides which calls reach the file system. Its rules are checked
+// in order, and the first one matching a call decides it.
+type Policy struct {
+	file string
+
+	mu       sync.RWMutex
+	rules    []Rule
+	allowAll bool
+}
+
+// NewPolicy returns a policy with rules, which denies calls matching
+// none of them unless allowByDefault is set.
+func NewPolicy(rules []Rule, allowByDefault bool) *Policy {
+	p := &Policy{}
+	p.Set(rules, allowByDefault)
+	return p
+}
+
+// LoadPolicy reads a policy from a JSON file, which is read again by
+// Reload.
+func LoadPolicy(file string) (*Policy, error) {
+	p := &Policy{file: file}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload reads the policy's file again, e.g. on SIGHUP. If that fails,
+// the rules in use stay unchanged.
+func (p *Policy) Reload() error {
+	if p.file == "" {
+		return fmt.Errorf("policy wasn't loaded from a file")
+	}
+	data, err := ioutil.ReadFile(p.file)
+	if err != nil {
+		return err
+	}
+	var f policyFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("parsing %s: %v", p.file, err)
+	}
+	switch f.Default {
+	case "", "deny", "allow":
+	default:
+		return fmt.Errorf("parsing %s: default is %q, not allow or deny", p.file, f.Default)
+	}
+	for _, r := range f.Rules {
+		if _, err := path.Match(strings.TrimSuffix(r.Path, "/**"), ""); err != nil {
+			return fmt.Errorf("parsing %s: path %q: %v", p.file, r.Path, err)
+		}
+		for _, o := range r.Ops {
+			if o != ReadOps && o != WriteOps {
+				return fmt.Errorf("parsing %s: unknown ops %q", p.file, o)
+			}
+		}
+	}
+	p.Set(f.Rules, f.Default == "allow")
+	return nil
+}
+
+// Set replaces the rules of the policy.
+func (p *Policy) Set(rules []Rule, allowByDefault bool) {
+	p.mu.Lock()
+	p.rules = rules
+	p.allowAll = allowByDefault
+	p.mu.Unlock()
+}
+
+// Allowed reports whether principal may make calls of class op on name.
+func (p *Policy) Allowed(principal, name string, op OpClass) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for i := range p.rules {
+		if p.rules[i].matches(principal, name, op) {
+			return p.rules[i].Allow
+		}
+	}
+	return p.allowAll
+}
+
+// allowed reports whether the policy of the server, if any, allows the
+// principal of ctx calls of class op on name.
+func (s *fuseServer) allowed(ctx context.Context, name string, op OpClass, follow bool) bool {
+	if s.policy == nil {
+		return true
+	}
+	principal := principalName(ctx)
+	if !s.policy.allows(principal, name, op, follow, nil) {
+		log.Printf("Policy denies %q %s access to %q", principal, op, name)
+		return false
+	}
+	return true
+}
+
+// policyFileSystem checks the calls to the file system against a policy.
+// StatFs has no context to tell the principal, so the server checks it
+// itself, as well as the events sent by Watch. Calls on open files aren't
+// checked again: the policy is applied when they are opened, and their
+// handles can only be used by the principal which opened them.
+type policyFileSystem struct {
+	pathfs.FileSystem
+	policy *Policy
+}
+
+// allows reports whether principal may make calls of class op on name,
+// both as given and as the file system resolves it, so symlinks can't
+// lead around the rules. With follow set, a symlink name ends in is
+// followed, as calls on its target do. Paths which can't be resolved,
+// e.g. because they lead out of the export, are denied.
+func (fs *policyFileSystem) allows(principal, name string, op OpClass, follow bool, context *fuse.Context) bool {
+	if !fs.policy.Allowed(principal, name, op) {
+		return false
+	}
+	resolved, ok := resolvePath(fs.FileSystem, name, follow, context)
+	return ok && (resolved == name || fs.policy.Allowed(principal, resolved, op))
+}
+
+// check returns EACCES if the call of context on names isn't allowed.
+func (fs *policyFileSystem) check(context *fuse.Context, op OpClass, follow bool, names ...string) fuse.Status {
+	var principal string
+	if p, ok := PrincipalOf(context); ok {
+		principal = p.Name
+	}
+	for _, name := range names {
+		if !fs.allows(principal, name, op, follow, context) {
+			log.Printf("Policy denies %q %s access to %q", principal, op, name)
+			return fuse.EACCES
+		}
+	}
+	return fuse.OK
+}
+
+func openClass(flags uint32) OpClass {
+	if flags&fuse.O_ANYWRITE != 0 {
+		return WriteOps
+	}
+	return ReadOps
+}
+
+func (fs *policyFileSystem) GetAttr(name string, context *fuse.Context) (*fuse.Attr, fuse.Status) {
+	if code := fs.check(context, ReadOps, false, name); code != fuse.OK {
+		return nil, code
+	}
+	return fs.FileSystem.GetAttr(name, context)
+}
+
+func (fs *policyFileSystem) Chmod(name string, mode uint32, context *fuse.Context) fuse.Status {
+	if code := fs.check(context, WriteOps, true, name); code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Chmod(name, mode, context)
+}
+
+func (fs *policyFileSystem) Chown(name string, uid uint32, gid uint32, context *fuse.Context) fuse.Status {
+	if code := fs.check(context, WriteOps, true, name); code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Chown(name, uid, gid, context)
+}
+
+func (fs *policyFileSystem) Utimens(name string, atime *time.Time, mtime *time.Time, context *fuse.Context) fuse.Status {
+	if code := fs.check(context, WriteOps, true, name); code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Utimens(name, atime, mtime, context)
+}
+
+func (fs *policyFileSystem) Truncate(name string, size uint64, context *fuse.Context) fuse.Status {
+	if code := fs.check(context, WriteOps, true, name); code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Truncate(name, size, context)
+}
+
+func (fs *policyFileSystem) Access(name string, mode uint32, context *fuse.Context) fuse.Status {
+	op := ReadOps
+	if mode&wOK != 0 {
+		op = WriteOps
+	}
+	if code := fs.check(context, op, true, name); code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Access(name, mode, context)
+}
+
+func (fs *policyFileSystem) Link(oldName string, newName string, context *fuse.Context) fuse.Status {
+	if code := fs.check(context, WriteOps, false, oldName, newName); code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Link(oldName, newName, context)
+}
+
+func (fs *policyFileSystem) Mkdir(name string, mode uint32, context *fuse.Context) fuse.Status {
+	if code := fs.check(context, WriteOps, false, name); code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Mkdir(name, mode, context)
+}
+
+func (fs *policyFileSystem) Mknod(name string, mode uint32, dev uint32, context *fuse.Context) fuse.Status {
+	if code := fs.check(context, WriteOps, false, name); code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Mknod(name, mode, dev, context)
+}
+
+func (fs *policyFileSystem) Rename(oldName string, newName string, context *fuse.Context) fuse.Status {
+	if code := fs.check(context, WriteOps, false, oldName, newName); code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Rename(oldName, newName, context)
+}
+
+func (fs *policyFileSystem) Rmdir(name string, context *fuse.Context) fuse.Status {
+	if code := fs.check(context, WriteOps, false, name); code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Rmdir(name, context)
+}
+
+func (fs *policyFileSystem) Unlink(name string, context *fuse.Context) fuse.Status {
+	if code := fs.check(context, WriteOps, false, name); code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Unlink(name, context)
+}
+
+func (fs *policyFileSystem) GetXAttr(name string, attribute string, context *fuse.Context) ([]byte, fuse.Status) {
+	if code := fs.check(context, ReadOps, true, name); code != fuse.OK {
+		return nil, code
+	}
+	return fs.FileSystem.GetXAttr(name, attribute, context)
+}
+
+func (fs *policyFileSystem) ListXAttr(name string, context *fuse.Context) ([]string, fuse.Status) {
+	if code := fs.check(context, ReadOps, true, name); code != fuse.OK {
+		return nil, code
+	}
+	return fs.FileSystem.ListXAttr(name, context)
+}
+
+func (fs *policyFileSystem) RemoveXAttr(name string, attr string, context *fuse.Context) fuse.Status {
+	if code := fs.check(context, WriteOps, true, name); code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.RemoveXAttr(name, attr, context)
+}
+
+func (fs *policyFileSystem) SetXAttr(name string, attr string, data []byte, flags int, context *fuse.Context) fuse.Status {
+	if code := fs.check(context, WriteOps, true, name); code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.SetXAttr(name, attr, data, flags, context)
+}
+
+func (fs *policyFileSystem) Open(name string, flags uint32, context *fuse.Context) (nodefs.File, fuse.Status) {
+	if code := fs.check(context, openClass(flags), true, name); code != fuse.OK {
+		return nil, code
+	}
+	return fs.FileSystem.Open(name, flags, context)
+}
+
+func (fs *policyFileSystem) Create(name string, flags uint32, mode uint32, context *fuse.Context) (nodefs.File, fuse.Status) {
+	if code := fs.check(context, WriteOps, true, name); code != fuse.OK {
+		return nil, code
+	}
+	return fs.FileSystem.Create(name, flags, mode, context)
+}
+
+func (fs *policyFileSystem) OpenDir(name string, context *fuse.Context) ([]fuse.DirEntry, fuse.Status) {
+	if code := fs.check(context, ReadOps, true, name); code != fuse.OK {
+		return nil, code
+	}
+	return fs.FileSystem.OpenDir(name, context)
+}
+
+func (fs *policyFileSystem) Symlink(value string, linkName string, context *fuse.Context) fuse.Status {
+	if code := fs.check(context, WriteOps, false, linkName); code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Symlink(value, linkName, context)
+}
+
+func (fs *policyFileSystem) Readlink(name string, context *fuse.Context) (string, fuse.Status) {
+	if code := fs.check(context, ReadOps, false, name); code != fuse.OK {
+		return "", code
+	}
+	return fs.FileSystem.Readlink(name, context)
+}
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/LK4D4/grfuse/pb"
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// linkFs keeps directories and symlinks in memory.
+type linkFs struct {
+	pathfs.FileSystem
+	dirs  map[string]bool
+	links map[string]string
+}
+
+func newLinkFs(dirs ...string) *linkFs {
+	fs := &linkFs{
+		FileSystem: pathfs.NewDefaultFileSystem(),
+		dirs:       map[string]bool{"": true},
+		links:      make(map[string]string),
+	}
+	for _, d := range dirs {
+		fs.dirs[d] = true
+	}
+	return fs
+}
+
+func (fs *linkFs) GetAttr(name string, context *fuse.Context) (*fuse.Attr, fuse.Status) {
+	if fs.dirs[name] {
+		return &fuse.Attr{Mode: syscall.S_IFDIR | 0755}, fuse.OK
+	}
+	if _, ok := fs.links[name]; ok {
+		return &fuse.Attr{Mode: syscall.S_IFLNK | 0777}, fuse.OK
+	}
+	return nil, fuse.ENOENT
+}
+
+func (fs *linkFs) Readlink(name string, context *fuse.Context) (string, fuse.Status) {
+	if target, ok := fs.links[name]; ok {
+		return target, fuse.OK
+	}
+	return "", fuse.ENOENT
+}
+
+func (fs *linkFs) Symlink(value string, linkName string, context *fuse.Context) fuse.Status {
+	fs.links[linkName] = value
+	return fuse.OK
+}
+
+func (fs *linkFs) Rename(oldName string, newName string, context *fuse.Context) fuse.Status {
+	fs.links[newName] = fs.links[oldName]
+	delete(fs.links, oldName)
+	return fuse.OK
+}
+
+func (fs *linkFs) Link(oldName string, newName string, context *fuse.Context) fuse.Status {
+	fs.links[newName] = fs.links[oldName]
+	return fuse.OK
+}
+
+func TestMatchPath(t *testing.T) {
+	for _, c := range []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"**", "", true},
+		{"**", "a/b", true},
+		{"a", "a", true},
+		{"a", "a/b", false},
+		{"*.txt", "a.txt", true},
+		{"*.txt", "dir/a.txt", false},
+		{"dir/**", "dir", true},
+		{"dir/**", "dir/a/b", true},
+		{"dir/**", "dirt", false},
+		{"dir/**", "other/dir", false},
+		{"*/cache/**", "a/cache/b", true},
+		{"*/cache/**", "a/b/cache", false},
+	} {
+		if got := matchPath(c.pattern, c.name); got != c.want {
+			t.Errorf("matchPath(%q, %q) = %v, want %v", c.pattern, c.name, got, c.want)
+		}
+	}
+}
+
+func TestPolicyAllowed(t *testing.T) {
+	policy := NewPolicy([]Rule{
+		{Principal: "bob", Path: "private/**", Allow: false},
+		{Principal: "bob", Path: "**", Ops: []OpClass{ReadOps}, Allow: true},
+		{Principal: "*", Path: "pub/**", Allow: true},
+	}, false)
+	for _, c := range []struct {
+		principal, name string
+		op              OpClass
+		want            bool
+	}{
+		{"bob", "file", ReadOps, true},
+		{"bob", "file", WriteOps, false},
+		// The first matching rule decides.
+		{"bob", "private/file", ReadOps, false},
+		{"bob", "pub/file", WriteOps, true},
+		{"carol", "pub/file", WriteOps, true},
+		{"carol", "file", ReadOps, false},
+		{"", "pub", ReadOps, true},
+		{"", "file", ReadOps, false},
+	} {
+		if got := policy.Allowed(c.principal, c.name, c.op); got != c.want {
+			t.Errorf("%q doing %s on %q: allowed is %v, want %v", c.principal, c.op, c.name, got, c.want)
+		}
+	}
+}
+
+func TestPolicyReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "policy-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "policy.json")
+	writePolicy := func(s string) {
+		if err := ioutil.WriteFile(file, []byte(s), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writePolicy(`{"rules": [
+		{"principal": "bob", "path": "**", "ops": ["read"], "allow": true}
+	]}`)
+	policy, err := LoadPolicy(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !policy.Allowed("bob", "file", ReadOps) || policy.Allowed("carol", "file", ReadOps) {
+		t.Error("loaded policy doesn't allow only bob")
+	}
+
+	writePolicy(`{"default": "allow", "rules": [
+		{"principal": "bob", "path": "dir/**", "allow": false}
+	]}`)
+	if err := policy.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if policy.Allowed("bob", "dir/sub", WriteOps) || !policy.Allowed("carol", "file", ReadOps) {
+		t.Error("reloaded policy isn't used")
+	}
+
+	for _, invalid := range []string{
+		`{"default": "sometimes"}`,
+		`{"rules": [{"path": "[", "allow": true}]}`,
+		`{"rules": [{"path": "**", "ops": ["delete"], "allow": true}]}`,
+		`{`,
+	} {
+		writePolicy(invalid)
+		if err := policy.Reload(); err == nil {
+			t.Errorf("loaded invalid policy %s", invalid)
+		}
+	}
+	if !policy.Allowed("carol", "file", ReadOps) {
+		t.Error("failed reload changed the policy")
+	}
+}
+
+func TestPolicyServer(t *testing.T) {
+	policy := NewPolicy([]Rule{{Principal: "bob", Path: "**", Ops: []OpClass{ReadOps}, Allow: true}}, false)
+	s := newTestServer(t, newTestFs(nil), Options{Policy: policy})
+	gctx := &pb.Context{Owner: &pb.Owner{}}
+	for _, c := range []struct {
+		principal *Principal
+		read      bool
+	}{
+		{&Principal{Name: "bob"}, true},
+		{&Principal{Name: "carol"}, false},
+		{nil, false},
+	} {
+		ctx := context.Background()
+		if c.principal != nil {
+			ctx = withPrincipal(ctx, c.principal)
+		}
+		getAttr, err := s.GetAttr(ctx, &pb.GetAttrRequest{Name: "file", Context: gctx})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed := getAttr.Status.Code != fuse.EACCES; allowed != c.read {
+			t.Errorf("GetAttr by %+v: got %v", c.principal, getAttr.Status.Code)
+		}
+		mkdir, err := s.Mkdir(ctx, &pb.MkdirRequest{Name: "dir/sub", Mode: 0755, Context: gctx})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mkdir.Status.Code != fuse.EACCES {
+			t.Errorf("Mkdir by %+v: got %v, want EACCES", c.principal, mkdir.Status.Code)
+		}
+	}
+}
+
+func TestPolicySymlinks(t *testing.T) {
+	backend := newLinkFs("pub", "secret")
+	backend.links["pub/s"] = "../secret"
+	backend.links["pub/abs"] = "/etc"
+	policy := NewPolicy([]Rule{{Principal: "*", Path: "secret/**", Allow: false}}, true)
+	s := newTestServer(t, backend, Options{Policy: policy})
+	ctx := context.Background()
+	gctx := &pb.Context{Owner: &pb.Owner{}}
+
+	for _, c := range []struct {
+		name   string
+		denied bool
+	}{
+		{"pub", false},
+		{"secret/file", true},
+		{"pub/s/file", true},
+		// The symlink itself isn't in the denied directory.
+		{"pub/s", false},
+	} {
+		resp, err := s.GetAttr(ctx, &pb.GetAttrRequest{Name: c.name, Context: gctx})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if denied := resp.Status.Code == fuse.EACCES; denied != c.denied {
+			t.Errorf("GetAttr %q: got %v, want denied %v", c.name, resp.Status.Code, c.denied)
+		}
+	}
+	for _, name := range []string{"pub/s", "pub/s/file", "pub/abs"} {
+		resp, err := s.Open(ctx, &pb.OpenRequest{Name: name, Flags: uint32(os.O_RDONLY), Context: gctx})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Status.Code != fuse.EACCES {
+			t.Errorf("Open %q: got %v, want EACCES", name, resp.Status.Code)
+		}
+	}
+	mkdir, err := s.Mkdir(ctx, &pb.MkdirRequest{Name: "pub/s/dir", Mode: 0755, Context: gctx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mkdir.Status.Code != fuse.EACCES {
+		t.Errorf("Mkdir through symlink: got %v, want EACCES", mkdir.Status.Code)
+	}
+	if _, err := s.StatFs(ctx, &pb.StatFsRequest{Name: "pub/s"}); grpc.Code(err) != codes.PermissionDenied {
+		t.Errorf("StatFs through symlink: got %v, want PermissionDenied", err)
+	}
+	if e := s.visible(ctx, &pb.WatchEvent{Op: pb.WatchOp_Create, Name: "pub/s/file"}); e != nil {
+		t.Errorf("event through symlink sent as %v", e)
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/nodefs"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+)
+
+// erofs is returned for every attempt to modify a read-only export.
+const erofs = fuse.Status(syscall.EROFS)
+
+// wOK is the access mode asking for write permission.
+const wOK = 2
+
+// readOnlyFileSystem rejects all calls modifying the file system. Files
+// can't be opened for writing, so open files needn't be wrapped, though
+// the server still rejects writes to them.
+type readOnlyFileSystem struct {
+	pathfs.FileSystem
+}
+
+func (fs *readOnlyFileSystem) Chmod(name string, mode uint32, context *fuse.Context) fuse.Status {
+	return erofs
+}
+
+func (fs *readOnlyFileSystem) Chown(name string, uid uint32, gid uint32, context *fuse.Context) fuse.Status {
+	return erofs
+}
+
+func (fs *readOnlyFileSystem) Utimens(name string, atime *time.Time, mtime *time.Time, context *fuse.Context) fuse.Status {
+	return erofs
+}
+
+func (fs *readOnlyFileSystem) Truncate(name string, size uint64, context *fuse.Context) fuse.Status {
+	return erofs
+}
+
+func (fs *readOnlyFileSystem) Access(name string, mode uint32, context *fuse.Context) fuse.Status {
+	if mode&wOK != 0 {
+		return erofs
+	}
+	return fs.FileSystem.Access(name, mode, context)
+}
+
+func (fs *readOnlyFileSystem) Link(oldName string, newName string, context *fuse.Context) fuse.Status {
+	return erofs
+}
+
+func (fs *readOnlyFileSystem) Mkdir(name string, mode uint32, context *fuse.Context) fuse.Status {
+	return erofs
+}
+
+func (fs *readOnlyFileSystem) Mknod(name string, mode uint32, dev uint32, context *fuse.Context) fuse.Status {
+	return erofs
+}
+
+func (fs *readOnlyFileSystem) Rename(oldName string, newName string, context *fuse.Context) fuse.Status {
+	return erofs
+}
+
+func (fs *readOnlyFileSystem) Rmdir(name string, context *fuse.Context) fuse.Status {
+	return erofs
+}
+
+func (fs *readOnlyFileSystem) Unlink(name string, context *fuse.Context) fuse.Status {
+	return erofs
+}
+
+func (fs *readOnlyFileSystem) RemoveXAttr(name string, attr string, context *fuse.Context) fuse.Status {
+	return erofs
+}
+
+func (fs *readOnlyFileSystem) SetXAttr(name string, attr string, data []byte, flags int, context *fuse.Context) fuse.Status {
+	return erofs
+}
+
+func (fs *readOnlyFileSystem) Open(name string, flags uint32, context *fuse.Context) (nodefs.File, fuse.Status) {
+	if flags&fuse.O_ANYWRITE != 0 {
+		return nil, erofs
+	}
+	return fs.FileSystem.Open(name, flags, context)
+}
+
+func (fs *readOnlyFileSystem) Create(name string, flags uint32, mode uint32, context *fuse.Context) (nodefs.File, fuse.Status) {
+	return nil, erofs
+}
+
+func (fs *readOnlyFileSystem) Symlink(value string, linkName string, context *fuse.Context) fuse.Status {
+	return erofs
+}
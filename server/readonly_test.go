@@ -0,0 +1,71 @@
+package server
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/LK4D4/grfuse/pb"
+	"github.com/hanwen/go-fuse/fuse"
+	"golang.org/x/net/context"
+)
+
+func TestReadOnly(t *testing.T) {
+	s := newTestServer(t, newTestFs([]byte("data")), Options{ReadOnly: true})
+	ctx := context.Background()
+	gctx := &pb.Context{Owner: &pb.Owner{}}
+	erofs := fuse.Status(syscall.EROFS)
+
+	hello, err := s.Hello(ctx, &pb.HelloRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, f := range hello.Features {
+		found = found || f == pb.Feature_ReadOnly
+	}
+	if !found {
+		t.Error("Hello doesn't advertise read-only export")
+	}
+	for _, c := range []struct {
+		op   string
+		call func() (*pb.Status, error)
+	}{
+		{"Mkdir", func() (*pb.Status, error) {
+			resp, err := s.Mkdir(ctx, &pb.MkdirRequest{Name: "dir", Mode: 0755, Context: gctx})
+			return resp.GetStatus(), err
+		}},
+		{"Unlink", func() (*pb.Status, error) {
+			resp, err := s.Unlink(ctx, &pb.UnlinkRequest{Name: "file", Context: gctx})
+			return resp.GetStatus(), err
+		}},
+		{"Chmod", func() (*pb.Status, error) {
+			resp, err := s.Chmod(ctx, &pb.ChmodRequest{Name: "file", Mode: 0600, Context: gctx})
+			return resp.GetStatus(), err
+		}},
+		{"Open for writing", func() (*pb.Status, error) {
+			resp, err := s.Open(ctx, &pb.OpenRequest{Name: "file", Flags: uint32(os.O_RDWR), Context: gctx})
+			return resp.GetStatus(), err
+		}},
+		{"Create", func() (*pb.Status, error) {
+			resp, err := s.Create(ctx, &pb.CreateRequest{Name: "new", Flags: uint32(os.O_WRONLY), Mode: 0644, Context: gctx})
+			return resp.GetStatus(), err
+		}},
+	} {
+		st, err := c.call()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.Code != erofs {
+			t.Errorf("%s: got %v, want EROFS", c.op, st.Code)
+		}
+	}
+	open(t, ctx, s)
+	statFs, err := s.StatFs(ctx, &pb.StatFsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !statFs.ReadOnly {
+		t.Error("StatFs doesn't report read-only export")
+	}
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"log"
+	"runtime/debug"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// UnaryRecoverInterceptor makes calls which panic, e.g. in the file
+// system, fail with codes.Internal instead of crashing the server, and
+// with it the mounts of all clients. The panic is logged with its stack.
+// Install it as the innermost interceptor:
+//
+//	s := grpc.NewServer(
+//		grpc.UnaryInterceptor(server.UnaryRecoverInterceptor()),
+//		grpc.StreamInterceptor(server.StreamRecoverInterceptor()),
+//	)
+func UnaryRecoverInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer recoverCall(info.FullMethod, &err)
+		return handler(ctx, req)
+	}
+}
+
+// StreamRecoverInterceptor is UnaryRecoverInterceptor for streams.
+func StreamRecoverInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer recoverCall(info.FullMethod, &err)
+		return handler(srv, ss)
+	}
+}
+
+// recoverCall sets *err if the call of method panics.
+func recoverCall(method string, err *error) {
+	if r := recover(); r != nil {
+		log.Printf("Panic in %s: %v\n%s", method, r, debug.Stack())
+		*err = grpc.Errorf(codes.Internal, "panic in %s", method)
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"path"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+)
+
+// maxSymlinks is the most symlinks followed while resolving a path, as
+// MAXSYMLINKS.
+const maxSymlinks = 40
+
+// resolvePath follows the symlinks among the components of name in fs, as
+// the file system would, and returns the path it leads to relative to the
+// root of the export. A symlink as the last component is only followed
+// with follow set, as calls like Lstat and Unlink don't follow it. Missing
+// components are taken to be directories. It reports false if the path
+// leads out of the export, or can't be resolved.
+func resolvePath(fs pathfs.FileSystem, name string, follow bool, context *fuse.Context) (string, bool) {
+	if !follow {
+		dir, ok := resolvePath(fs, path.Dir(name), true, context)
+		return path.Join(dir, path.Base(name)), ok
+	}
+	resolved := ""
+	pending := strings.Split(name, "/")
+	for links := 0; len(pending) > 0; {
+		c := pending[0]
+		pending = pending[1:]
+		switch c {
+		case "", ".":
+			continue
+		case "..":
+			if resolved == "" {
+				return "", false
+			}
+			if resolved = path.Dir(resolved); resolved == "." {
+				resolved = ""
+			}
+			continue
+		}
+		next := path.Join(resolved, c)
+		attr, code := fs.GetAttr(next, context)
+		if code == fuse.ENOENT || code == fuse.OK && attr.Mode&syscall.S_IFMT != syscall.S_IFLNK {
+			resolved = next
+			continue
+		}
+		if code != fuse.OK {
+			return "", false
+		}
+		if links++; links > maxSymlinks {
+			return "", false
+		}
+		target, code := fs.Readlink(next, context)
+		if code != fuse.OK || path.IsAbs(target) {
+			return "", false
+		}
+		pending = append(strings.Split(target, "/"), pending...)
+	}
+	return resolved, true
+}
@@ -0,0 +1,296 @@
+package server
+
+import (
+	"log"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/nodefs"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+)
+
+const (
+	// maxNameLen is the longest name of a single file, as NAME_MAX.
+	maxNameLen = 255
+	// maxPathLen is the longest path, and symlink target, as PATH_MAX.
+	maxPathLen = 4096
+)
+
+// cleanPath returns name in the form the file system expects, relative to
+// the root of the export, or an error if it can't be one sent by a sane
+// client.
+func cleanPath(name string) (string, fuse.Status) {
+	if name == "" {
+		return "", fuse.OK
+	}
+	if len(name) > maxPathLen {
+		return "", fuse.Status(syscall.ENAMETOOLONG)
+	}
+	if strings.IndexByte(name, 0) >= 0 || path.IsAbs(name) {
+		return "", fuse.EINVAL
+	}
+	for _, c := range strings.Split(name, "/") {
+		if c == ".." {
+			return "", fuse.EINVAL
+		}
+		if len(c) > maxNameLen {
+			return "", fuse.Status(syscall.ENAMETOOLONG)
+		}
+	}
+	name = path.Clean(name)
+	if name == "." {
+		name = ""
+	}
+	return name, fuse.OK
+}
+
+// sanitizingFileSystem rejects paths which are absolute, leave the export
+// or are too long, and cleans the others, before they reach the file
+// system.
+type sanitizingFileSystem struct {
+	pathfs.FileSystem
+	// confineSymlinks rejects symlinks leading out of the export.
+	confineSymlinks bool
+}
+
+func (fs *sanitizingFileSystem) clean(name string) (string, fuse.Status) {
+	clean, code := cleanPath(name)
+	if code != fuse.OK {
+		log.Printf("Rejecting path %q: %v", name, syscall.Errno(code))
+	}
+	return clean, code
+}
+
+func (fs *sanitizingFileSystem) clean2(oldName, newName string) (string, string, fuse.Status) {
+	oldName, code := fs.clean(oldName)
+	if code != fuse.OK {
+		return "", "", code
+	}
+	newName, code = fs.clean(newName)
+	return oldName, newName, code
+}
+
+// confined reports whether a symlink at linkName to target leads to a
+// path inside the export, given the symlinks there now.
+func (fs *sanitizingFileSystem) confined(linkName, target string, context *fuse.Context) bool {
+	if path.IsAbs(target) {
+		return false
+	}
+	_, ok := resolvePath(fs.FileSystem, path.Dir(linkName)+"/"+target, true, context)
+	return ok
+}
+
+// checkMove checks that renaming or linking oldName to newName doesn't
+// move a symlink to where it leads out of the export.
+func (fs *sanitizingFileSystem) checkMove(oldName, newName string, context *fuse.Context) fuse.Status {
+	if !fs.confineSymlinks {
+		return fuse.OK
+	}
+	attr, code := fs.FileSystem.GetAttr(oldName, context)
+	if code != fuse.OK || attr.Mode&syscall.S_IFMT != syscall.S_IFLNK {
+		// Failures are left to the call itself.
+		return fuse.OK
+	}
+	target, code := fs.FileSystem.Readlink(oldName, context)
+	if code != fuse.OK {
+		return code
+	}
+	if !fs.confined(newName, target, context) {
+		log.Printf("Rejecting move of symlink %q to %q outside the export to %q", oldName, target, newName)
+		return fuse.EPERM
+	}
+	return fuse.OK
+}
+
+func (fs *sanitizingFileSystem) GetAttr(name string, context *fuse.Context) (*fuse.Attr, fuse.Status) {
+	name, code := fs.clean(name)
+	if code != fuse.OK {
+		return nil, code
+	}
+	return fs.FileSystem.GetAttr(name, context)
+}
+
+func (fs *sanitizingFileSystem) Chmod(name string, mode uint32, context *fuse.Context) fuse.Status {
+	name, code := fs.clean(name)
+	if code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Chmod(name, mode, context)
+}
+
+func (fs *sanitizingFileSystem) Chown(name string, uid uint32, gid uint32, context *fuse.Context) fuse.Status {
+	name, code := fs.clean(name)
+	if code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Chown(name, uid, gid, context)
+}
+
+func (fs *sanitizingFileSystem) Utimens(name string, atime *time.Time, mtime *time.Time, context *fuse.Context) fuse.Status {
+	name, code := fs.clean(name)
+	if code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Utimens(name, atime, mtime, context)
+}
+
+func (fs *sanitizingFileSystem) Truncate(name string, size uint64, context *fuse.Context) fuse.Status {
+	name, code := fs.clean(name)
+	if code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Truncate(name, size, context)
+}
+
+func (fs *sanitizingFileSystem) Access(name string, mode uint32, context *fuse.Context) fuse.Status {
+	name, code := fs.clean(name)
+	if code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Access(name, mode, context)
+}
+
+func (fs *sanitizingFileSystem) Link(oldName string, newName string, context *fuse.Context) fuse.Status {
+	oldName, newName, code := fs.clean2(oldName, newName)
+	if code != fuse.OK {
+		return code
+	}
+	if code := fs.checkMove(oldName, newName, context); code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Link(oldName, newName, context)
+}
+
+func (fs *sanitizingFileSystem) Mkdir(name string, mode uint32, context *fuse.Context) fuse.Status {
+	name, code := fs.clean(name)
+	if code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Mkdir(name, mode, context)
+}
+
+func (fs *sanitizingFileSystem) Mknod(name string, mode uint32, dev uint32, context *fuse.Context) fuse.Status {
+	name, code := fs.clean(name)
+	if code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Mknod(name, mode, dev, context)
+}
+
+func (fs *sanitizingFileSystem) Rename(oldName string, newName string, context *fuse.Context) fuse.Status {
+	oldName, newName, code := fs.clean2(oldName, newName)
+	if code != fuse.OK {
+		return code
+	}
+	if code := fs.checkMove(oldName, newName, context); code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Rename(oldName, newName, context)
+}
+
+func (fs *sanitizingFileSystem) Rmdir(name string, context *fuse.Context) fuse.Status {
+	name, code := fs.clean(name)
+	if code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Rmdir(name, context)
+}
+
+func (fs *sanitizingFileSystem) Unlink(name string, context *fuse.Context) fuse.Status {
+	name, code := fs.clean(name)
+	if code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.Unlink(name, context)
+}
+
+func (fs *sanitizingFileSystem) GetXAttr(name string, attribute string, context *fuse.Context) ([]byte, fuse.Status) {
+	name, code := fs.clean(name)
+	if code != fuse.OK {
+		return nil, code
+	}
+	return fs.FileSystem.GetXAttr(name, attribute, context)
+}
+
+func (fs *sanitizingFileSystem) ListXAttr(name string, context *fuse.Context) ([]string, fuse.Status) {
+	name, code := fs.clean(name)
+	if code != fuse.OK {
+		return nil, code
+	}
+	return fs.FileSystem.ListXAttr(name, context)
+}
+
+func (fs *sanitizingFileSystem) RemoveXAttr(name string, attr string, context *fuse.Context) fuse.Status {
+	name, code := fs.clean(name)
+	if code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.RemoveXAttr(name, attr, context)
+}
+
+func (fs *sanitizingFileSystem) SetXAttr(name string, attr string, data []byte, flags int, context *fuse.Context) fuse.Status {
+	name, code := fs.clean(name)
+	if code != fuse.OK {
+		return code
+	}
+	return fs.FileSystem.SetXAttr(name, attr, data, flags, context)
+}
+
+func (fs *sanitizingFileSystem) Open(name string, flags uint32, context *fuse.Context) (nodefs.File, fuse.Status) {
+	name, code := fs.clean(name)
+	if code != fuse.OK {
+		return nil, code
+	}
+	return fs.FileSystem.Open(name, flags, context)
+}
+
+func (fs *sanitizingFileSystem) Create(name string, flags uint32, mode uint32, context *fuse.Context) (nodefs.File, fuse.Status) {
+	name, code := fs.clean(name)
+	if code != fuse.OK {
+		return nil, code
+	}
+	return fs.FileSystem.Create(name, flags, mode, context)
+}
+
+func (fs *sanitizingFileSystem) OpenDir(name string, context *fuse.Context) ([]fuse.DirEntry, fuse.Status) {
+	name, code := fs.clean(name)
+	if code != fuse.OK {
+		return nil, code
+	}
+	return fs.FileSystem.OpenDir(name, context)
+}
+
+func (fs *sanitizingFileSystem) Symlink(value string, linkName string, context *fuse.Context) fuse.Status {
+	linkName, code := fs.clean(linkName)
+	if code != fuse.OK {
+		return code
+	}
+	if value == "" || len(value) > maxPathLen || strings.IndexByte(value, 0) >= 0 {
+		log.Printf("Rejecting symlink %q to %q", linkName, value)
+		return fuse.EINVAL
+	}
+	if fs.confineSymlinks && !fs.confined(linkName, value, context) {
+		log.Printf("Rejecting symlink %q to %q outside the export", linkName, value)
+		return fuse.EPERM
+	}
+	return fs.FileSystem.Symlink(value, linkName, context)
+}
+
+func (fs *sanitizingFileSystem) Readlink(name string, context *fuse.Context) (string, fuse.Status) {
+	name, code := fs.clean(name)
+	if code != fuse.OK {
+		return "", code
+	}
+	return fs.FileSystem.Readlink(name, context)
+}
+
+func (fs *sanitizingFileSystem) StatFs(name string) *fuse.StatfsOut {
+	name, code := fs.clean(name)
+	if code != fuse.OK {
+		return nil
+	}
+	return fs.FileSystem.StatFs(name)
+}
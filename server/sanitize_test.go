@@ -0,0 +1,94 @@
+package server
+
+import (
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/LK4D4/grfuse/pb"
+	"github.com/hanwen/go-fuse/fuse"
+	"golang.org/x/net/context"
+)
+
+func TestCleanPath(t *testing.T) {
+	for _, c := range []struct {
+		name, want string
+		code       fuse.Status
+	}{
+		{"", "", fuse.OK},
+		{".", "", fuse.OK},
+		{"file", "file", fuse.OK},
+		{"./file", "file", fuse.OK},
+		{"dir//file/", "dir/file", fuse.OK},
+		{"dir/./file", "dir/file", fuse.OK},
+		{"..", "", fuse.EINVAL},
+		{"../../etc/shadow", "", fuse.EINVAL},
+		// Even if it stays inside, as dir may be a symlink.
+		{"dir/../file", "", fuse.EINVAL},
+		{"/etc/shadow", "", fuse.EINVAL},
+		{"file\x00", "", fuse.EINVAL},
+		{"..file", "..file", fuse.OK},
+		{strings.Repeat("a", maxNameLen), strings.Repeat("a", maxNameLen), fuse.OK},
+		{strings.Repeat("a", maxNameLen+1), "", fuse.Status(syscall.ENAMETOOLONG)},
+		{strings.Repeat("a/", maxPathLen/2+1), "", fuse.Status(syscall.ENAMETOOLONG)},
+	} {
+		got, code := cleanPath(c.name)
+		if got != c.want || code != c.code {
+			t.Errorf("cleanPath(%q) = %q, %v, want %q, %v", c.name, got, code, c.want, c.code)
+		}
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	s := newTestServer(t, newTestFs(nil), Options{})
+	gctx := &pb.Context{Owner: &pb.Owner{}}
+	for name, want := range map[string]fuse.Status{
+		"file":        fuse.OK,
+		"./file":      fuse.OK,
+		"../file":     fuse.EINVAL,
+		"/etc/shadow": fuse.EINVAL,
+	} {
+		resp, err := s.GetAttr(context.Background(), &pb.GetAttrRequest{Name: name, Context: gctx})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Status.Code != want {
+			t.Errorf("GetAttr %q: got %v, want %v", name, resp.Status.Code, want)
+		}
+	}
+}
+
+func TestConfineSymlinks(t *testing.T) {
+	backend := newLinkFs("a", "a/b", "sub")
+	fs := &sanitizingFileSystem{FileSystem: backend, confineSymlinks: true}
+	ctx := &fuse.Context{}
+
+	for _, c := range []struct {
+		target, link string
+		want         fuse.Status
+	}{
+		{"../../x", "a/b/l", fuse.OK},
+		{"../../../x", "a/b/l2", fuse.EPERM},
+		{"/etc", "abs", fuse.EPERM},
+		{"..", "sub/x", fuse.OK},
+		// sub/x is the root, so this leads out of it.
+		{"../..", "sub/x/y", fuse.EPERM},
+		{"sub/x/..", "up", fuse.EPERM},
+		{"sub/x/a", "down", fuse.OK},
+	} {
+		if code := fs.Symlink(c.target, c.link, ctx); code != c.want {
+			t.Errorf("symlink %s to %s: got %v, want %v", c.link, c.target, code, c.want)
+		}
+	}
+
+	// a/b/l leads to x, but wouldn't one level up.
+	if code := fs.Rename("a/b/l", "l", ctx); code != fuse.EPERM {
+		t.Errorf("renaming symlink out of the export: got %v, want EPERM", code)
+	}
+	if code := fs.Link("a/b/l", "a/l", ctx); code != fuse.EPERM {
+		t.Errorf("linking symlink out of the export: got %v, want EPERM", code)
+	}
+	if code := fs.Rename("a/b/l", "a/b/l3", ctx); code != fuse.OK {
+		t.Errorf("renaming symlink in place: got %v", code)
+	}
+}
@@ -1,255 +1,805 @@
 package server
 
 import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/LK4D4/grfuse/pb"
 	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/nodefs"
 	"github.com/hanwen/go-fuse/fuse/pathfs"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	// defaultChunkSize is the chunk size used by ReadStream if the
+	// client doesn't ask for one.
+	defaultChunkSize = 64 << 10
+	// maxChunkSize bounds the chunk size a client can ask for, so a
+	// single chunk always fits into a gRPC message.
+	maxChunkSize = 1 << 20
+	// dirBatchSize is the number of entries sent in one OpenDirStream
+	// message.
+	dirBatchSize = 1024
+	// defaultMaxMessageSize is the size of the largest message a gRPC
+	// server accepts by default.
+	defaultMaxMessageSize = 4 << 20
+	// maxLegacyFileSize is the largest file sent whole by Open, which
+	// has to fit into a message clients accept by default.
+	maxLegacyFileSize = defaultMaxMessageSize - 4<<10
 )
 
 type fuseServer struct {
-	fs pathfs.FileSystem
+	fs      pathfs.FileSystem
+	session uint64
+	limits  pb.Limits
+	// features lists what the server implements.
+	features []pb.Feature
+	readOnly bool
+	policy   *policyFileSystem
+	idMap    *IDMap
+	handles  *handleTable
+	watches  *watchHub
+	// debug is set while debugging, accessed atomically.
+	debug           int32
+	debugPrincipals []string
 }
 
-func fuseContext(gctx *pb.Context) *fuse.Context {
-	if gctx == nil {
-		return nil
-	}
-	ctx := &fuse.Context{
-		Pid: gctx.Pid,
+// Options configures the server returned by NewWithOptions.
+type Options struct {
+	// Changes reports all changes made to the file system, which are
+	// sent to Watch streams. Without it, only the changes made through
+	// the server are sent. May be nil.
+	Changes ChangeSource
+	// MaxMessageSize is the largest message the gRPC server accepts,
+	// as set with grpc.MaxRecvMsgSize. It is advertised to clients,
+	// which keep their messages below it. Zero means gRPC's default.
+	MaxMessageSize int
+	// ReadOnly exports the file system read-only: calls modifying it
+	// fail with EROFS, and clients are told so they can mount it
+	// read-only.
+	ReadOnly bool
+	// Policy decides which calls reach the file system, by the
+	// principal authenticated by Auth. Denied calls fail with EACCES.
+	// May be nil.
+	Policy *Policy
+	// ConfineSymlinks rejects with EPERM creating symlinks whose target
+	// is absolute or leads out of the export, following the symlinks
+	// already there, and renaming or linking symlinks to where they
+	// would. Later changes, like moving a directory holding relative
+	// symlinks elsewhere, aren't checked, so file systems which must
+	// never leave their root have to resolve paths beneath it
+	// themselves. Paths sent by clients are always confined to the
+	// export, before symlinks are followed.
+	ConfineSymlinks bool
+	// IDMap maps the uids and gids of clients to those of the server.
+	// May be nil.
+	IDMap *IDMap
+	// HandleTimeout is how long files opened by clients stay open
+	// without being used, after which they are released and clients
+	// have to open them again. Zero means an hour, negative forever.
+	HandleTimeout time.Duration
+	// Debug starts the server logging every call.
+	Debug bool
+	// DebugPrincipals are the names of the principals which may turn
+	// debugging of the whole server on and off with SetDebug, "*" for
+	// every client. SetDebug fails with codes.PermissionDenied for
+	// others.
+	DebugPrincipals []string
+}
+
+// fuseContext returns the file system context of a call made with ctx.
+// The owner is mapped to the server's ids, and calls which didn't send
+// one act as the anonymous user and group, so they can't escape
+// squashing by acting as the server itself.
+func (s *fuseServer) fuseContext(ctx context.Context, gctx *pb.Context) *fuse.Context {
+	fctx := &fuse.Context{
+		Owner: fuse.Owner{
+			Uid: s.idMap.anonUID(),
+			Gid: s.idMap.anonGID(),
+		},
 	}
-	if gctx.Owner != nil {
-		ctx.Owner = fuse.Owner{
-			Uid: gctx.Owner.Uid,
-			Gid: gctx.Owner.Gid,
+	if gctx != nil {
+		fctx.Pid = gctx.Pid
+		if gctx.Owner != nil {
+			fctx.Owner.Uid = s.idMap.serverUID(gctx.Owner.Uid)
+			fctx.Owner.Gid = s.idMap.serverGID(gctx.Owner.Gid)
 		}
 	}
-	return ctx
+	addContext(ctx, fctx)
+	return fctx
+}
+
+// ctxErr returns the error a call ends with if the client has given up
+// on it, or nil. Calls into the file system can't be interrupted, but
+// further work is skipped.
+func ctxErr(ctx context.Context) error {
+	switch ctx.Err() {
+	case context.Canceled:
+		return grpc.Errorf(codes.Canceled, "call canceled by client")
+	case context.DeadlineExceeded:
+		return grpc.Errorf(codes.DeadlineExceeded, "call deadline exceeded")
+	}
+	return nil
+}
+
+// newStatus returns the status for code, which the file system returned
+// for the operation on path. Failures carry details for the client's
+// logs, naming the call of ctx and path.
+func newStatus(ctx context.Context, code fuse.Status, path string) *pb.Status {
+	st := &pb.Status{Code: code}
+	if code != fuse.OK {
+		st.Path = path
+		st.Message = fmt.Sprintf("%s %q: %v", callOp(ctx), path, syscall.Errno(code))
+	}
+	return st
+}
+
+// badHandle is the status for calls with a handle the handle table
+// rejected with code. Unknown handles were most likely opened before the
+// server restarted, and clients can open the file again. Handles opened
+// by other principals can't be used at all.
+func badHandle(ctx context.Context, h uint64, code fuse.Status) *pb.Status {
+	st := &pb.Status{
+		Code:    code,
+		Message: fmt.Sprintf("%s: unknown file handle %d", callOp(ctx), h),
+	}
+	if code == fuse.EBADF {
+		st.Message = fmt.Sprintf("%s: file handle %d was opened by another client", callOp(ctx), h)
+	}
+	return st
 }
 
 func New(fs pathfs.FileSystem) pb.PathFSServer {
-	return &fuseServer{fs: fs}
+	return NewWithOptions(fs, Options{})
+}
+
+func NewWithOptions(fs pathfs.FileSystem, opts Options) pb.PathFSServer {
+	watches := newWatchHub(opts.Changes)
+	handleTimeout := opts.HandleTimeout
+	if handleTimeout == 0 {
+		handleTimeout = defaultHandleTimeout
+	}
+	maxMessageSize := opts.MaxMessageSize
+	if maxMessageSize == 0 {
+		maxMessageSize = defaultMaxMessageSize
+	}
+	session := newSession()
+	features := append([]pb.Feature(nil), baseFeatures...)
+	if opts.ReadOnly {
+		fs = &readOnlyFileSystem{FileSystem: fs}
+		features = append(features, pb.Feature_ReadOnly)
+	}
+	var policy *policyFileSystem
+	if opts.Policy != nil {
+		policy = &policyFileSystem{FileSystem: fs, policy: opts.Policy}
+		fs = policy
+	}
+	fs = &sanitizingFileSystem{
+		FileSystem: &notifyingFileSystem{
+			FileSystem: fs,
+			watches:    watches,
+		},
+		confineSymlinks: opts.ConfineSymlinks,
+	}
+	var debug int32
+	if opts.Debug {
+		debug = 1
+		fs.SetDebug(true)
+	}
+	return &fuseServer{
+		fs:       fs,
+		session:  session,
+		features: features,
+		readOnly: opts.ReadOnly,
+		policy:   policy,
+		idMap:    opts.IDMap,
+		limits: pb.Limits{
+			MaxMessageSize: uint32(maxMessageSize),
+			MaxChunkSize:   maxChunkSize,
+			DirBatchSize:   dirBatchSize,
+		},
+		handles:         newHandleTable(handleTimeout),
+		watches:         watches,
+		debug:           debug,
+		debugPrincipals: opts.DebugPrincipals,
+	}
 }
 
 func (s *fuseServer) String(ctx context.Context, r *pb.StringRequest) (*pb.StringResponse, error) {
+	ctx, done := s.startCall(ctx, "String", "")
+	defer done()
 	return &pb.StringResponse{
 		String_: s.fs.String(),
 	}, nil
 }
 
 func (s *fuseServer) SetDebug(ctx context.Context, r *pb.SetDebugRequest) (*pb.SetDebugResponse, error) {
+	ctx, done := s.startCall(ctx, "SetDebug", "")
+	defer done()
+	if !s.mayDebug(ctx) {
+		return nil, grpc.Errorf(codes.PermissionDenied, "client may not change debugging of the server")
+	}
+	var v int32
+	if r.Debug {
+		v = 1
+	}
+	atomic.StoreInt32(&s.debug, v)
 	s.fs.SetDebug(r.Debug)
 	return &pb.SetDebugResponse{}, nil
 }
 
+// pbAttr returns attr for the client, with the owner mapped to its ids.
+func (s *fuseServer) pbAttr(attr *fuse.Attr) *pb.Attr {
+	return &pb.Attr{
+		Ino:       attr.Ino,
+		SizeAttr:  attr.Size,
+		Blocks:    attr.Blocks,
+		Atime:     attr.Atime,
+		Mtime:     attr.Mtime,
+		Ctime:     attr.Ctime,
+		Atimensec: attr.Atimensec,
+		Mtimensec: attr.Mtimensec,
+		Ctimensec: attr.Ctimensec,
+		Mode:      attr.Mode,
+		Nlink:     attr.Nlink,
+		Owner: &pb.Owner{
+			Uid: s.idMap.clientUID(attr.Owner.Uid),
+			Gid: s.idMap.clientGID(attr.Owner.Gid),
+		},
+		Rdev:    attr.Rdev,
+		Blksize: attr.Blksize,
+		Padding: attr.Padding,
+	}
+}
+
 func (s *fuseServer) GetAttr(ctx context.Context, r *pb.GetAttrRequest) (*pb.GetAttrResponse, error) {
-	attr, code := s.fs.GetAttr(r.Name, fuseContext(r.Context))
+	ctx, done := s.startCall(ctx, "GetAttr", r.Name)
+	defer done()
+	attr, code := s.fs.GetAttr(r.Name, s.fuseContext(ctx, r.Context))
 	resp := &pb.GetAttrResponse{
-		Status: &pb.Status{
-			Code: code,
-		},
+		Status: newStatus(ctx, code, r.Name),
 	}
 	if code == fuse.OK {
-		resp.Attr = &pb.Attr{
-			Ino:       attr.Ino,
-			SizeAttr:  attr.Size,
-			Blocks:    attr.Blocks,
-			Atime:     attr.Atime,
-			Mtime:     attr.Mtime,
-			Ctime:     attr.Ctime,
-			Atimensec: attr.Atimensec,
-			Mtimensec: attr.Mtimensec,
-			Ctimensec: attr.Ctimensec,
-			Mode:      attr.Mode,
-			Nlink:     attr.Nlink,
-			Owner: &pb.Owner{
-				Uid: attr.Owner.Uid,
-				Gid: attr.Owner.Gid,
-			},
-			Rdev:    attr.Rdev,
-			Blksize: attr.Blksize,
-			Padding: attr.Padding,
-		}
+		resp.Attr = s.pbAttr(attr)
 	}
 	return resp, nil
 }
 
 func (s *fuseServer) Chmod(ctx context.Context, r *pb.ChmodRequest) (*pb.ChmodResponse, error) {
+	ctx, done := s.startCall(ctx, "Chmod", r.Name)
+	defer done()
 	return &pb.ChmodResponse{
-		Status: &pb.Status{Code: s.fs.Chmod(r.Name, r.Mode, fuseContext(r.Context))},
+		Status: newStatus(ctx, s.fs.Chmod(r.Name, r.Mode, s.fuseContext(ctx, r.Context)), r.Name),
 	}, nil
 }
 
 func (s *fuseServer) Chown(ctx context.Context, r *pb.ChownRequest) (*pb.ChownResponse, error) {
+	ctx, done := s.startCall(ctx, "Chown", r.Name)
+	defer done()
+	uid, uidOK := s.idMap.chownUID(r.UID)
+	gid, gidOK := s.idMap.chownGID(r.GID)
+	if !uidOK || !gidOK {
+		return &pb.ChownResponse{
+			Status: newStatus(ctx, fuse.EINVAL, r.Name),
+		}, nil
+	}
 	return &pb.ChownResponse{
-		Status: &pb.Status{Code: s.fs.Chown(r.Name, r.UID, r.GID, fuseContext(r.Context))},
+		Status: newStatus(ctx, s.fs.Chown(r.Name, uid, gid, s.fuseContext(ctx, r.Context)), r.Name),
 	}, nil
 }
 
 func (s *fuseServer) Utimens(ctx context.Context, r *pb.UtimensRequest) (*pb.UtimensResponse, error) {
+	ctx, done := s.startCall(ctx, "Utimens", r.Name)
+	defer done()
 	atime := time.Unix(0, r.Atime)
 	mtime := time.Unix(0, r.Mtime)
 	return &pb.UtimensResponse{
-		Status: &pb.Status{Code: s.fs.Utimens(r.Name, &atime, &mtime, fuseContext(r.Context))},
+		Status: newStatus(ctx, s.fs.Utimens(r.Name, &atime, &mtime, s.fuseContext(ctx, r.Context)), r.Name),
 	}, nil
 }
 
 func (s *fuseServer) Truncate(ctx context.Context, r *pb.TruncateRequest) (*pb.TruncateResponse, error) {
+	ctx, done := s.startCall(ctx, "Truncate", r.Name)
+	defer done()
 	return &pb.TruncateResponse{
-		Status: &pb.Status{Code: s.fs.Truncate(r.Name, r.Size_, fuseContext(r.Context))},
+		Status: newStatus(ctx, s.fs.Truncate(r.Name, r.Size_, s.fuseContext(ctx, r.Context)), r.Name),
 	}, nil
 }
 
 func (s *fuseServer) Access(ctx context.Context, r *pb.AccessRequest) (*pb.AccessResponse, error) {
+	ctx, done := s.startCall(ctx, "Access", r.Name)
+	defer done()
 	return &pb.AccessResponse{
-		Status: &pb.Status{Code: s.fs.Access(r.Name, r.Mode, fuseContext(r.Context))},
+		Status: newStatus(ctx, s.fs.Access(r.Name, r.Mode, s.fuseContext(ctx, r.Context)), r.Name),
 	}, nil
 }
 
 func (s *fuseServer) Link(ctx context.Context, r *pb.LinkRequest) (*pb.LinkResponse, error) {
+	ctx, done := s.startCall(ctx, "Link", r.OldName)
+	defer done()
 	return &pb.LinkResponse{
-		Status: &pb.Status{Code: s.fs.Link(r.OldName, r.NewName, fuseContext(r.Context))},
+		Status: newStatus(ctx, s.fs.Link(r.OldName, r.NewName, s.fuseContext(ctx, r.Context)), r.OldName),
 	}, nil
 }
 
 func (s *fuseServer) Mkdir(ctx context.Context, r *pb.MkdirRequest) (*pb.MkdirResponse, error) {
+	ctx, done := s.startCall(ctx, "Mkdir", r.Name)
+	defer done()
 	return &pb.MkdirResponse{
-		Status: &pb.Status{Code: s.fs.Mkdir(r.Name, r.Mode, fuseContext(r.Context))},
+		Status: newStatus(ctx, s.fs.Mkdir(r.Name, r.Mode, s.fuseContext(ctx, r.Context)), r.Name),
 	}, nil
 }
 
 func (s *fuseServer) Mknod(ctx context.Context, r *pb.MknodRequest) (*pb.MknodResponse, error) {
+	ctx, done := s.startCall(ctx, "Mknod", r.Name)
+	defer done()
 	return &pb.MknodResponse{
-		Status: &pb.Status{Code: s.fs.Mknod(r.Name, r.Mode, r.Dev, fuseContext(r.Context))},
+		Status: newStatus(ctx, s.fs.Mknod(r.Name, r.Mode, r.Dev, s.fuseContext(ctx, r.Context)), r.Name),
 	}, nil
 }
 
 func (s *fuseServer) Rename(ctx context.Context, r *pb.RenameRequest) (*pb.RenameResponse, error) {
+	ctx, done := s.startCall(ctx, "Rename", r.OldName)
+	defer done()
 	return &pb.RenameResponse{
-		Status: &pb.Status{Code: s.fs.Rename(r.OldName, r.NewName, fuseContext(r.Context))},
+		Status: newStatus(ctx, s.fs.Rename(r.OldName, r.NewName, s.fuseContext(ctx, r.Context)), r.OldName),
 	}, nil
 }
 
 func (s *fuseServer) Rmdir(ctx context.Context, r *pb.RmdirRequest) (*pb.RmdirResponse, error) {
+	ctx, done := s.startCall(ctx, "Rmdir", r.Name)
+	defer done()
 	return &pb.RmdirResponse{
-		Status: &pb.Status{Code: s.fs.Rmdir(r.Name, fuseContext(r.Context))},
+		Status: newStatus(ctx, s.fs.Rmdir(r.Name, s.fuseContext(ctx, r.Context)), r.Name),
 	}, nil
 }
 
 func (s *fuseServer) Unlink(ctx context.Context, r *pb.UnlinkRequest) (*pb.UnlinkResponse, error) {
+	ctx, done := s.startCall(ctx, "Unlink", r.Name)
+	defer done()
 	return &pb.UnlinkResponse{
-		Status: &pb.Status{Code: s.fs.Unlink(r.Name, fuseContext(r.Context))},
+		Status: newStatus(ctx, s.fs.Unlink(r.Name, s.fuseContext(ctx, r.Context)), r.Name),
 	}, nil
 }
 
 func (s *fuseServer) GetXAttr(ctx context.Context, r *pb.GetXAttrRequest) (*pb.GetXAttrResponse, error) {
-	data, code := s.fs.GetXAttr(r.Name, r.Attribute, fuseContext(r.Context))
+	ctx, done := s.startCall(ctx, "GetXAttr", r.Name)
+	defer done()
+	data, code := s.fs.GetXAttr(r.Name, r.Attribute, s.fuseContext(ctx, r.Context))
 	return &pb.GetXAttrResponse{
 		Data:   data,
-		Status: &pb.Status{Code: code},
+		Status: newStatus(ctx, code, r.Name),
 	}, nil
 }
 
 func (s *fuseServer) ListXAttr(ctx context.Context, r *pb.ListXAttrRequest) (*pb.ListXAttrResponse, error) {
-	attrs, code := s.fs.ListXAttr(r.Name, fuseContext(r.Context))
+	ctx, done := s.startCall(ctx, "ListXAttr", r.Name)
+	defer done()
+	attrs, code := s.fs.ListXAttr(r.Name, s.fuseContext(ctx, r.Context))
 	return &pb.ListXAttrResponse{
 		Attributes: attrs,
-		Status:     &pb.Status{Code: code},
+		Status:     newStatus(ctx, code, r.Name),
 	}, nil
 }
 
 func (s *fuseServer) RemoveXAttr(ctx context.Context, r *pb.RemoveXAttrRequest) (*pb.RemoveXAttrResponse, error) {
+	ctx, done := s.startCall(ctx, "RemoveXAttr", r.Name)
+	defer done()
 	return &pb.RemoveXAttrResponse{
-		Status: &pb.Status{Code: s.fs.RemoveXAttr(r.Name, r.Attribute, fuseContext(r.Context))},
+		Status: newStatus(ctx, s.fs.RemoveXAttr(r.Name, r.Attribute, s.fuseContext(ctx, r.Context)), r.Name),
 	}, nil
 }
 
 func (s *fuseServer) SetXAttr(ctx context.Context, r *pb.SetXAttrRequest) (*pb.SetXAttrResponse, error) {
+	ctx, done := s.startCall(ctx, "SetXAttr", r.Name)
+	defer done()
 	return &pb.SetXAttrResponse{
-		Status: &pb.Status{Code: s.fs.SetXAttr(r.Name, r.Attribute, r.Data, r.Flags, fuseContext(r.Context))},
+		Status: newStatus(ctx, s.fs.SetXAttr(r.Name, r.Attribute, r.Data, r.Flags, s.fuseContext(ctx, r.Context)), r.Name),
 	}, nil
 }
 
 func (s *fuseServer) Open(ctx context.Context, r *pb.OpenRequest) (*pb.OpenResponse, error) {
-	f, code := s.fs.Open(r.Name, r.Flags, fuseContext(r.Context))
+	ctx, done := s.startCall(ctx, "Open", r.Name)
+	defer done()
+	f, code := s.fs.Open(r.Name, r.Flags, s.fuseContext(ctx, r.Context))
 	resp := &pb.OpenResponse{
-		Status: &pb.Status{Code: code},
+		Status: newStatus(ctx, code, r.Name),
 	}
 	if code != fuse.OK {
 		return resp, nil
 	}
+	if !r.Handle {
+		data, code := fileData(f)
+		f.Release()
+		if code != fuse.OK {
+			resp.Status = newStatus(ctx, code, r.Name)
+			return resp, nil
+		}
+		resp.File = &pb.File{
+			Data: data,
+		}
+		return resp, nil
+	}
+	resp.File = &pb.File{
+		Handle: s.handles.add(r.Name, principalName(ctx), f),
+	}
+	return resp, nil
+}
+
+// fileData reads all of f, for clients from before handles, which get
+// the contents of files from Open.
+func fileData(f nodefs.File) ([]byte, fuse.Status) {
 	attr := &fuse.Attr{}
 	if code := f.GetAttr(attr); code != fuse.OK {
-		return &pb.OpenResponse{
-			Status: &pb.Status{Code: code},
-		}, nil
+		return nil, code
+	}
+	if attr.Size > maxLegacyFileSize {
+		return nil, fuse.Status(syscall.EFBIG)
 	}
 	buf := make([]byte, attr.Size)
 	readResult, code := f.Read(buf, 0)
 	if code != fuse.OK {
-		return &pb.OpenResponse{
-			Status: &pb.Status{Code: code},
-		}, nil
+		return nil, code
+	}
+	data, code := readResult.Bytes(buf)
+	readResult.Done()
+	return data, code
+}
+
+func (s *fuseServer) Create(ctx context.Context, r *pb.CreateRequest) (*pb.CreateResponse, error) {
+	ctx, done := s.startCall(ctx, "Create", r.Name)
+	defer done()
+	f, code := s.fs.Create(r.Name, r.Flags, r.Mode, s.fuseContext(ctx, r.Context))
+	resp := &pb.CreateResponse{
+		Status: newStatus(ctx, code, r.Name),
 	}
-	buf, code = readResult.Bytes(buf)
 	if code != fuse.OK {
-		return &pb.OpenResponse{
-			Status: &pb.Status{Code: code},
-		}, nil
+		return resp, nil
 	}
 	resp.File = &pb.File{
-		Data: buf,
+		Handle: s.handles.add(r.Name, principalName(ctx), f),
 	}
 	return resp, nil
 }
 
-func (s *fuseServer) Create(ctx context.Context, r *pb.CreateRequest) (*pb.CreateResponse, error) {
-	// unimplemented until nodefs.File
-	return &pb.CreateResponse{
-		Status: &pb.Status{Code: fuse.ENOSYS},
+func (s *fuseServer) Read(ctx context.Context, r *pb.ReadRequest) (*pb.ReadResponse, error) {
+	ctx, done := s.startCall(ctx, "Read", s.handles.name(r.Handle))
+	defer done()
+	if err := ctxErr(ctx); err != nil {
+		return nil, err
+	}
+	f, code := s.handles.get(r.Handle, principalName(ctx))
+	if code != fuse.OK {
+		return &pb.ReadResponse{
+			Status: badHandle(ctx, r.Handle, code),
+		}, nil
+	}
+	// The size comes from the client, so it's bounded like the chunks
+	// of ReadStream. Reads by the kernel are smaller anyway.
+	size := r.Size_
+	if size > maxChunkSize {
+		size = maxChunkSize
+	}
+	buf := make([]byte, size)
+	readResult, code := f.Read(buf, r.Offset)
+	if code != fuse.OK {
+		return &pb.ReadResponse{
+			Status: newStatus(ctx, code, f.name),
+		}, nil
+	}
+	data, code := readResult.Bytes(buf)
+	readResult.Done()
+	return &pb.ReadResponse{
+		Data:   data,
+		Status: newStatus(ctx, code, f.name),
+	}, nil
+}
+
+func (s *fuseServer) ReadStream(r *pb.ReadStreamRequest, stream pb.PathFS_ReadStreamServer) error {
+	ctx, done := s.startCall(stream.Context(), "ReadStream", s.handles.name(r.Handle))
+	defer done()
+	f, code := s.handles.get(r.Handle, principalName(ctx))
+	if code != fuse.OK {
+		return stream.Send(&pb.ReadStreamResponse{
+			Offset: r.Offset,
+			Status: badHandle(ctx, r.Handle, code),
+		})
+	}
+	chunkSize := int64(r.ChunkSize)
+	if chunkSize == 0 {
+		chunkSize = defaultChunkSize
+	}
+	if chunkSize > maxChunkSize {
+		chunkSize = maxChunkSize
+	}
+	buf := make([]byte, chunkSize)
+	off := r.Offset
+	for r.Size_ == 0 || off < r.Offset+r.Size_ {
+		n := chunkSize
+		if r.Size_ != 0 && r.Offset+r.Size_-off < n {
+			n = r.Offset + r.Size_ - off
+		}
+		if err := ctxErr(ctx); err != nil {
+			return err
+		}
+		f.touch()
+		readResult, code := f.Read(buf[:n], off)
+		if code != fuse.OK {
+			return stream.Send(&pb.ReadStreamResponse{
+				Offset: off,
+				Status: newStatus(ctx, code, f.name),
+			})
+		}
+		data, code := readResult.Bytes(buf[:n])
+		if code != fuse.OK {
+			readResult.Done()
+			return stream.Send(&pb.ReadStreamResponse{
+				Offset: off,
+				Status: newStatus(ctx, code, f.name),
+			})
+		}
+		if len(data) == 0 {
+			readResult.Done()
+			return nil
+		}
+		err := stream.Send(&pb.ReadStreamResponse{
+			Data:   data,
+			Offset: off,
+			Status: newStatus(ctx, fuse.OK, ""),
+		})
+		readResult.Done()
+		if err != nil {
+			return err
+		}
+		off += int64(len(data))
+	}
+	return nil
+}
+
+func (s *fuseServer) Write(ctx context.Context, r *pb.WriteRequest) (*pb.WriteResponse, error) {
+	ctx, done := s.startCall(ctx, "Write", s.handles.name(r.Handle))
+	defer done()
+	if err := ctxErr(ctx); err != nil {
+		return nil, err
+	}
+	if s.readOnly {
+		return &pb.WriteResponse{
+			Status: newStatus(ctx, erofs, s.handles.name(r.Handle)),
+		}, nil
+	}
+	f, code := s.handles.get(r.Handle, principalName(ctx))
+	if code != fuse.OK {
+		return &pb.WriteResponse{
+			Status: badHandle(ctx, r.Handle, code),
+		}, nil
+	}
+	written, code := f.Write(r.Data, r.Offset)
+	return &pb.WriteResponse{
+		Written: written,
+		Status:  newStatus(ctx, code, f.name),
+	}, nil
+}
+
+func (s *fuseServer) WriteStream(stream pb.PathFS_WriteStreamServer) error {
+	ctx, done := s.startCall(stream.Context(), "WriteStream", "")
+	defer done()
+	var (
+		f      *openFile
+		handle uint64
+		off    int64
+	)
+	for {
+		r, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if s.readOnly {
+			return stream.SendAndClose(&pb.WriteStreamResponse{
+				Offset: r.Offset,
+				Status: newStatus(ctx, erofs, s.handles.name(r.Handle)),
+			})
+		}
+		if f == nil {
+			var code fuse.Status
+			if f, code = s.handles.get(r.Handle, principalName(ctx)); code != fuse.OK {
+				return stream.SendAndClose(&pb.WriteStreamResponse{
+					Offset: r.Offset,
+					Status: badHandle(ctx, r.Handle, code),
+				})
+			}
+			handle = r.Handle
+			off = r.Offset
+		} else if r.Handle != handle {
+			st := newStatus(ctx, fuse.EINVAL, f.name)
+			st.Message = fmt.Sprintf("%s %q: chunk for handle %d on the stream of handle %d", callOp(ctx), f.name, r.Handle, handle)
+			return stream.SendAndClose(&pb.WriteStreamResponse{
+				Offset: off,
+				Status: st,
+			})
+		}
+		f.touch()
+		written, code := f.Write(r.Data, r.Offset)
+		if code == fuse.OK && int(written) < len(r.Data) {
+			code = fuse.EIO
+		}
+		st := newStatus(ctx, code, f.name)
+		if code == fuse.EIO && int(written) < len(r.Data) {
+			st.Message = fmt.Sprintf("short write of %d out of %d bytes", written, len(r.Data))
+		}
+		if st.Code != fuse.OK {
+			return stream.SendAndClose(&pb.WriteStreamResponse{
+				Offset: off,
+				Status: st,
+			})
+		}
+		off = r.Offset + int64(written)
+	}
+	return stream.SendAndClose(&pb.WriteStreamResponse{
+		Offset: off,
+		Status: newStatus(ctx, fuse.OK, ""),
+	})
+}
+
+func (s *fuseServer) Flush(ctx context.Context, r *pb.FlushRequest) (*pb.FlushResponse, error) {
+	ctx, done := s.startCall(ctx, "Flush", s.handles.name(r.Handle))
+	defer done()
+	f, code := s.handles.get(r.Handle, principalName(ctx))
+	if code != fuse.OK {
+		return &pb.FlushResponse{
+			Status: badHandle(ctx, r.Handle, code),
+		}, nil
+	}
+	return &pb.FlushResponse{
+		Status: newStatus(ctx, f.Flush(), f.name),
+	}, nil
+}
+
+func (s *fuseServer) Fsync(ctx context.Context, r *pb.FsyncRequest) (*pb.FsyncResponse, error) {
+	ctx, done := s.startCall(ctx, "Fsync", s.handles.name(r.Handle))
+	defer done()
+	f, code := s.handles.get(r.Handle, principalName(ctx))
+	if code != fuse.OK {
+		return &pb.FsyncResponse{
+			Status: badHandle(ctx, r.Handle, code),
+		}, nil
+	}
+	return &pb.FsyncResponse{
+		Status: newStatus(ctx, f.Fsync(r.Flags), f.name),
+	}, nil
+}
+
+func (s *fuseServer) Release(ctx context.Context, r *pb.ReleaseRequest) (*pb.ReleaseResponse, error) {
+	ctx, done := s.startCall(ctx, "Release", s.handles.name(r.Handle))
+	defer done()
+	f, code := s.handles.remove(r.Handle, principalName(ctx))
+	if code != fuse.OK {
+		return &pb.ReleaseResponse{
+			Status: badHandle(ctx, r.Handle, code),
+		}, nil
+	}
+	f.Release()
+	return &pb.ReleaseResponse{
+		Status: newStatus(ctx, fuse.OK, f.name),
 	}, nil
 }
 
 func (s *fuseServer) OpenDir(ctx context.Context, r *pb.OpenDirRequest) (*pb.OpenDirResponse, error) {
-	de, code := s.fs.OpenDir(r.Name, fuseContext(r.Context))
+	ctx, done := s.startCall(ctx, "OpenDir", r.Name)
+	defer done()
+	de, code := s.fs.OpenDir(r.Name, s.fuseContext(ctx, r.Context))
 	resp := &pb.OpenDirResponse{
-		Status: &pb.Status{Code: code},
+		Status: newStatus(ctx, code, r.Name),
 	}
 	if code != fuse.OK {
 		return resp, nil
 	}
+	dirs, err := s.dirEntries(ctx, r, de)
+	if err != nil {
+		return nil, err
+	}
+	resp.Dirs = dirs
+	return resp, nil
+}
+
+func (s *fuseServer) OpenDirStream(r *pb.OpenDirRequest, stream pb.PathFS_OpenDirStreamServer) error {
+	ctx, done := s.startCall(stream.Context(), "OpenDirStream", r.Name)
+	defer done()
+	de, code := s.fs.OpenDir(r.Name, s.fuseContext(ctx, r.Context))
+	if code != fuse.OK {
+		return stream.Send(&pb.OpenDirResponse{
+			Status: newStatus(ctx, code, r.Name),
+		})
+	}
+	for len(de) > 0 {
+		n := dirBatchSize
+		if n > len(de) {
+			n = len(de)
+		}
+		// Attributes are looked up per batch, so they are no older
+		// than the batch they are sent with.
+		dirs, err := s.dirEntries(ctx, r, de[:n])
+		if err != nil {
+			return err
+		}
+		resp := &pb.OpenDirResponse{
+			Dirs:   dirs,
+			Status: newStatus(ctx, fuse.OK, ""),
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+		de = de[n:]
+	}
+	return nil
+}
+
+// dirEntries converts the entries of the directory r.Name, adding their
+// attributes if the client asked for them. It stops early if ctx is done.
+func (s *fuseServer) dirEntries(ctx context.Context, r *pb.OpenDirRequest, de []fuse.DirEntry) ([]*pb.DirEntry, error) {
+	fctx := s.fuseContext(ctx, r.Context)
+	dirs := make([]*pb.DirEntry, 0, len(de))
 	for _, dir := range de {
-		resp.Dirs = append(resp.Dirs, &pb.DirEntry{
+		e := &pb.DirEntry{
 			Name: dir.Name,
 			Mode: dir.Mode,
-		})
+		}
+		if r.Plus {
+			if err := ctxErr(ctx); err != nil {
+				return nil, err
+			}
+			if attr, code := s.fs.GetAttr(filepath.Join(r.Name, dir.Name), fctx); code == fuse.OK {
+				e.Attr = s.pbAttr(attr)
+			}
+		}
+		dirs = append(dirs, e)
 	}
-
-	return resp, nil
+	return dirs, nil
 }
 
 func (s *fuseServer) Symlink(ctx context.Context, r *pb.SymlinkRequest) (*pb.SymlinkResponse, error) {
+	ctx, done := s.startCall(ctx, "Symlink", r.LinkName)
+	defer done()
 	return &pb.SymlinkResponse{
-		Status: &pb.Status{Code: s.fs.Symlink(r.Value, r.LinkName, fuseContext(r.Context))},
+		Status: newStatus(ctx, s.fs.Symlink(r.Value, r.LinkName, s.fuseContext(ctx, r.Context)), r.LinkName),
 	}, nil
 }
 
 func (s *fuseServer) Readlink(ctx context.Context, r *pb.ReadlinkRequest) (*pb.ReadlinkResponse, error) {
-	val, code := s.fs.Readlink(r.Name, fuseContext(r.Context))
+	ctx, done := s.startCall(ctx, "Readlink", r.Name)
+	defer done()
+	val, code := s.fs.Readlink(r.Name, s.fuseContext(ctx, r.Context))
 	return &pb.ReadlinkResponse{
 		Value:  val,
-		Status: &pb.Status{Code: code},
+		Status: newStatus(ctx, code, r.Name),
 	}, nil
 }
 
 func (s *fuseServer) StatFs(ctx context.Context, r *pb.StatFsRequest) (*pb.StatFsResponse, error) {
+	ctx, done := s.startCall(ctx, "StatFs", r.Name)
+	defer done()
+	// StatFs has no context in the file system, so the policy is
+	// checked here.
+	if name, code := cleanPath(r.Name); code == fuse.OK && !s.allowed(ctx, name, ReadOps, true) {
+		return nil, grpc.Errorf(codes.PermissionDenied, "policy denies access to %q", name)
+	}
 	statFs := s.fs.StatFs(r.Name)
 	if statFs == nil {
-		return &pb.StatFsResponse{}, nil
+		return &pb.StatFsResponse{
+			ReadOnly: s.readOnly,
+		}, nil
 	}
 	return &pb.StatFsResponse{
+		ReadOnly: s.readOnly,
 		StatFs: &pb.StatFs{
 			Blocks:  statFs.Blocks,
 			Bfree:   statFs.Bfree,
@@ -0,0 +1,588 @@
+package server
+
+import (
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/LK4D4/grfuse/pb"
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/nodefs"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// testFs serves "file" with the given contents, and records the contexts
+// it's called with.
+type testFs struct {
+	pathfs.FileSystem
+	data     []byte
+	contexts []*fuse.Context
+	released int
+}
+
+func newTestFs(data []byte) *testFs {
+	return &testFs{
+		FileSystem: pathfs.NewDefaultFileSystem(),
+		data:       data,
+	}
+}
+
+func (fs *testFs) GetAttr(name string, context *fuse.Context) (*fuse.Attr, fuse.Status) {
+	fs.contexts = append(fs.contexts, context)
+	switch name {
+	case "":
+		return &fuse.Attr{Mode: fuse.S_IFDIR | 0755}, fuse.OK
+	case "file":
+		return &fuse.Attr{Mode: fuse.S_IFREG | 0644, Size: uint64(len(fs.data))}, fuse.OK
+	}
+	return nil, fuse.ENOENT
+}
+
+func (fs *testFs) Chmod(name string, mode uint32, context *fuse.Context) fuse.Status {
+	return fuse.OK
+}
+
+func (fs *testFs) Open(name string, flags uint32, context *fuse.Context) (nodefs.File, fuse.Status) {
+	fs.contexts = append(fs.contexts, context)
+	if name != "file" {
+		return nil, fuse.ENOENT
+	}
+	return &testFile{File: nodefs.NewDataFile(fs.data), fs: fs}, fuse.OK
+}
+
+// testFile counts its releases, and accepts and drops writes.
+type testFile struct {
+	nodefs.File
+	fs *testFs
+}
+
+func (f *testFile) Release() {
+	f.fs.released++
+}
+
+func (f *testFile) Write(data []byte, off int64) (uint32, fuse.Status) {
+	return uint32(len(data)), fuse.OK
+}
+
+func newTestServer(t *testing.T, fs pathfs.FileSystem, opts Options) *fuseServer {
+	return NewWithOptions(fs, opts).(*fuseServer)
+}
+
+// open opens "file" for reading and returns its handle.
+func open(t *testing.T, ctx context.Context, s *fuseServer) uint64 {
+	resp, err := s.Open(ctx, &pb.OpenRequest{
+		Name:    "file",
+		Flags:   uint32(os.O_RDONLY),
+		Context: &pb.Context{Owner: &pb.Owner{}},
+		Handle:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status.Code != fuse.OK {
+		t.Fatalf("opening file: %v", resp.Status.Code)
+	}
+	return resp.File.Handle
+}
+
+func TestReadSizeBounded(t *testing.T) {
+	s := newTestServer(t, newTestFs(make([]byte, 2*maxChunkSize)), Options{})
+	ctx := context.Background()
+	h := open(t, ctx, s)
+	resp, err := s.Read(ctx, &pb.ReadRequest{Handle: h, Size_: ^uint32(0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status.Code != fuse.OK {
+		t.Fatal(resp.Status.Code)
+	}
+	if len(resp.Data) != maxChunkSize {
+		t.Fatalf("read %d bytes, want at most %d", len(resp.Data), maxChunkSize)
+	}
+}
+
+func TestHandleTimeout(t *testing.T) {
+	fs := newTestFs([]byte("data"))
+	s := newTestServer(t, fs, Options{HandleTimeout: 10 * time.Millisecond})
+	ctx := context.Background()
+	h := open(t, ctx, s)
+	time.Sleep(20 * time.Millisecond)
+	open(t, ctx, s)
+	if fs.released != 1 {
+		t.Fatalf("%d files released, want the unused one", fs.released)
+	}
+	resp, err := s.Read(ctx, &pb.ReadRequest{Handle: h, Size_: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status.Code != fuse.Status(syscall.ESTALE) {
+		t.Fatalf("reading expired handle: got %v, want ESTALE", resp.Status.Code)
+	}
+}
+
+func TestHandlesUnpredictable(t *testing.T) {
+	s := newTestServer(t, newTestFs([]byte("data")), Options{})
+	ctx := context.Background()
+	seen := make(map[uint64]bool)
+	prev := open(t, ctx, s)
+	for i := 0; i < 100; i++ {
+		h := open(t, ctx, s)
+		if seen[h] || h == prev+1 {
+			t.Fatalf("handle %d follows %d", h, prev)
+		}
+		seen[h] = true
+		prev = h
+	}
+}
+
+// writeStream is the server side of a WriteStream sending reqs.
+type writeStream struct {
+	grpc.ServerStream
+	reqs []*pb.WriteStreamRequest
+	resp *pb.WriteStreamResponse
+}
+
+func (s *writeStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *writeStream) Recv() (*pb.WriteStreamRequest, error) {
+	if len(s.reqs) == 0 {
+		return nil, io.EOF
+	}
+	r := s.reqs[0]
+	s.reqs = s.reqs[1:]
+	return r, nil
+}
+
+func (s *writeStream) SendAndClose(resp *pb.WriteStreamResponse) error {
+	s.resp = resp
+	return nil
+}
+
+func TestWriteStreamHandleMismatch(t *testing.T) {
+	s := newTestServer(t, newTestFs([]byte("data")), Options{})
+	ctx := context.Background()
+	h := open(t, ctx, s)
+	other := open(t, ctx, s)
+	stream := &writeStream{reqs: []*pb.WriteStreamRequest{
+		{Handle: h, Offset: 0, Data: []byte("x")},
+		{Handle: other, Offset: 1, Data: []byte("y")},
+	}}
+	if err := s.WriteStream(stream); err != nil {
+		t.Fatal(err)
+	}
+	if stream.resp.Status.Code != fuse.EINVAL {
+		t.Fatalf("chunk for another handle: got %v, want EINVAL", stream.resp.Status.Code)
+	}
+}
+
+// watchStream is a PathFS_WatchServer delivering events to a channel.
+type watchStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	events chan *pb.WatchEvent
+}
+
+func (s *watchStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *watchStream) Send(e *pb.WatchEvent) error {
+	s.events <- e
+	return nil
+}
+
+// clientContext returns the context of a call by the client id from addr.
+func clientContext(id, addr string) context.Context {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(clientIDKey, id))
+	return peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(addr), Port: 1234}})
+}
+
+func TestWatchOwnChanges(t *testing.T) {
+	s := newTestServer(t, newTestFs(nil), Options{})
+	ctx, cancel := context.WithCancel(clientContext("a", "10.0.0.1"))
+	defer cancel()
+	stream := &watchStream{ctx: ctx, events: make(chan *pb.WatchEvent, 10)}
+	go s.Watch(&pb.WatchRequest{}, stream)
+	if e := <-stream.events; e.Op != pb.WatchOp_Rescan {
+		t.Fatalf("got %v, want a Rescan first", e)
+	}
+	for _, c := range []struct {
+		ctx  context.Context
+		name string
+	}{
+		{clientContext("a", "10.0.0.1"), "own"},
+		{clientContext("a", "10.0.0.2"), "same id"},
+		{clientContext("b", "10.0.0.1"), "other id"},
+	} {
+		resp, err := s.Chmod(c.ctx, &pb.ChmodRequest{Name: c.name, Context: &pb.Context{Owner: &pb.Owner{}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Status.Code != fuse.OK {
+			t.Fatalf("chmod %s: %v", c.name, resp.Status.Code)
+		}
+	}
+	for _, want := range []string{"same id", "other id"} {
+		select {
+		case e := <-stream.events:
+			if e.Name != want {
+				t.Fatalf("got a change of %q, want %q", e.Name, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("change of %q wasn't sent", want)
+		}
+	}
+}
+
+// testSource is a ChangeSource reporting the events sent to it.
+type testSource chan *pb.WatchEvent
+
+func (s testSource) Events() <-chan *pb.WatchEvent {
+	return s
+}
+
+func TestWatchSource(t *testing.T) {
+	src := make(testSource)
+	s := newTestServer(t, newTestFs(nil), Options{Changes: src})
+	var streams []*watchStream
+	for _, id := range []string{"a", "b"} {
+		ctx, cancel := context.WithCancel(clientContext(id, "10.0.0.1"))
+		defer cancel()
+		stream := &watchStream{ctx: ctx, events: make(chan *pb.WatchEvent, 10)}
+		go s.Watch(&pb.WatchRequest{}, stream)
+		if e := <-stream.events; e.Op != pb.WatchOp_Rescan {
+			t.Fatalf("got %v, want a Rescan first", e)
+		}
+		streams = append(streams, stream)
+	}
+	resp, err := s.Chmod(clientContext("a", "10.0.0.1"), &pb.ChmodRequest{Name: "changed", Context: &pb.Context{Owner: &pb.Owner{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status.Code != fuse.OK {
+		t.Fatal(resp.Status.Code)
+	}
+	// The source reports the change of the client along with a local
+	// one.
+	src <- &pb.WatchEvent{Op: pb.WatchOp_Modify, Name: "changed"}
+	src <- &pb.WatchEvent{Op: pb.WatchOp_Modify, Name: "local"}
+	for i, want := range [][]string{{"local"}, {"changed", "local"}} {
+		for _, name := range want {
+			select {
+			case e := <-streams[i].events:
+				if e.Name != name {
+					t.Fatalf("watcher %d got a change of %q, want %q", i, e.Name, name)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("watcher %d didn't get the change of %q", i, name)
+			}
+		}
+	}
+}
+
+func TestStatusMessage(t *testing.T) {
+	s := newTestServer(t, newTestFs(nil), Options{})
+	resp, err := s.GetAttr(context.Background(), &pb.GetAttrRequest{Name: "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `GetAttr "missing": no such file or directory`; resp.Status.Message != want {
+		t.Fatalf("message %q, want %q", resp.Status.Message, want)
+	}
+	read, err := s.Read(context.Background(), &pb.ReadRequest{Handle: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Read: unknown file handle 1"; read.Status.Message != want {
+		t.Fatalf("message %q, want %q", read.Status.Message, want)
+	}
+}
+
+func TestRecoverInterceptor(t *testing.T) {
+	_, err := UnaryRecoverInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/pb.PathFS/GetAttr"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			panic("bug")
+		})
+	if grpc.Code(err) != codes.Internal {
+		t.Errorf("unary call panicking: got %v, want Internal", err)
+	}
+	err = StreamRecoverInterceptor()(nil, &writeStream{}, &grpc.StreamServerInfo{FullMethod: "/pb.PathFS/WriteStream"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			panic("bug")
+		})
+	if grpc.Code(err) != codes.Internal {
+		t.Errorf("stream panicking: got %v, want Internal", err)
+	}
+}
+
+func TestOpenWithoutHandle(t *testing.T) {
+	fs := newTestFs([]byte("data"))
+	s := newTestServer(t, fs, Options{})
+	resp, err := s.Open(context.Background(), &pb.OpenRequest{
+		Name:    "file",
+		Flags:   uint32(os.O_RDONLY),
+		Context: &pb.Context{Owner: &pb.Owner{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status.Code != fuse.OK {
+		t.Fatal(resp.Status.Code)
+	}
+	if resp.File.Handle != 0 || string(resp.File.Data) != "data" {
+		t.Fatalf("got %+v, want the contents without a handle", resp.File)
+	}
+	if fs.released != 1 {
+		t.Fatalf("%d files released, want the opened one", fs.released)
+	}
+}
+
+func TestSetDebugRestricted(t *testing.T) {
+	s := newTestServer(t, newTestFs(nil), Options{DebugPrincipals: []string{"admin"}})
+	for _, c := range []struct {
+		principal *Principal
+		allowed   bool
+	}{
+		{nil, false},
+		{&Principal{Name: "user"}, false},
+		{&Principal{Name: "admin"}, true},
+	} {
+		ctx := context.Background()
+		if c.principal != nil {
+			ctx = withPrincipal(ctx, c.principal)
+		}
+		_, err := s.SetDebug(ctx, &pb.SetDebugRequest{Debug: true})
+		if c.allowed && err != nil || !c.allowed && grpc.Code(err) != codes.PermissionDenied {
+			t.Errorf("SetDebug by %+v: got %v", c.principal, err)
+		}
+		if s.debugging() != c.allowed {
+			t.Errorf("SetDebug by %+v: debugging is %v", c.principal, s.debugging())
+		}
+	}
+}
+
+func TestRequestIDSanitized(t *testing.T) {
+	for _, c := range []struct {
+		sent, logged string
+	}{
+		{"abcd-1", "abcd-1"},
+		{"a\nb", `"a\nb"`},
+		{strings.Repeat("x", 1000), strings.Repeat("x", maxRequestIDLen)},
+	} {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestIDKey, c.sent))
+		if id := requestID(ctx); id != c.logged {
+			t.Errorf("request id %q logged as %s, want %s", c.sent, id, c.logged)
+		}
+	}
+}
+
+func TestAuthMissingOwner(t *testing.T) {
+	p := &Principal{
+		Name: "user",
+		UIDs: []IDRange{{1000, 1000}},
+		GIDs: []IDRange{{1000, 1000}},
+	}
+	for _, rewrite := range []bool{false, true} {
+		a := &Auth{Rewrite: rewrite}
+		if err := a.check(p, &pb.GetAttrRequest{Name: "file"}); grpc.Code(err) != codes.PermissionDenied {
+			t.Errorf("rewrite %v: call without context: got %v, want PermissionDenied", rewrite, err)
+		}
+		req := &pb.GetAttrRequest{Name: "file", Context: &pb.Context{}}
+		err := a.check(p, req)
+		switch {
+		case !rewrite && grpc.Code(err) != codes.PermissionDenied:
+			t.Errorf("call without owner: got %v, want PermissionDenied", err)
+		case rewrite && err != nil:
+			t.Errorf("call without owner: %v", err)
+		case rewrite && (req.Context.Owner.Uid != 1000 || req.Context.Owner.Gid != 1000):
+			t.Errorf("call without owner acts as %d:%d, want 1000:1000", req.Context.Owner.Uid, req.Context.Owner.Gid)
+		}
+	}
+}
+
+func TestHandleOwner(t *testing.T) {
+	fs := newTestFs([]byte("data"))
+	s := newTestServer(t, fs, Options{})
+	alice := withPrincipal(context.Background(), &Principal{Name: "alice"})
+	bob := withPrincipal(context.Background(), &Principal{Name: "bob"})
+	h := open(t, alice, s)
+
+	read, err := s.Read(bob, &pb.ReadRequest{Handle: h, Size_: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Status.Code != fuse.EBADF {
+		t.Fatalf("reading handle of another principal: got %v, want EBADF", read.Status.Code)
+	}
+	release, err := s.Release(bob, &pb.ReleaseRequest{Handle: h})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if release.Status.Code != fuse.EBADF || fs.released != 0 {
+		t.Fatalf("releasing handle of another principal: got %v", release.Status.Code)
+	}
+	if read, err = s.Read(alice, &pb.ReadRequest{Handle: h, Size_: 4}); err != nil || read.Status.Code != fuse.OK {
+		t.Fatalf("reading own handle: %v %v", err, read.GetStatus())
+	}
+	if release, err = s.Release(alice, &pb.ReleaseRequest{Handle: h}); err != nil || release.Status.Code != fuse.OK || fs.released != 1 {
+		t.Fatalf("releasing own handle: %v %v", err, release.GetStatus())
+	}
+}
+
+func TestReadOnlyWrite(t *testing.T) {
+	s := newTestServer(t, newTestFs([]byte("data")), Options{})
+	ctx := context.Background()
+	h := open(t, ctx, s)
+	// A handle given out before the export became read-only, or by a
+	// backend that ignores the open flags, can't write either.
+	s.readOnly = true
+	resp, err := s.Write(ctx, &pb.WriteRequest{Handle: h, Data: []byte("x")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status.Code != erofs {
+		t.Fatalf("Write: got %v, want EROFS", resp.Status.Code)
+	}
+	stream := &writeStream{reqs: []*pb.WriteStreamRequest{{Handle: h, Data: []byte("x")}}}
+	if err := s.WriteStream(stream); err != nil {
+		t.Fatal(err)
+	}
+	if stream.resp.Status.Code != erofs {
+		t.Fatalf("WriteStream: got %v, want EROFS", stream.resp.Status.Code)
+	}
+}
+
+func TestPolicyWithoutContext(t *testing.T) {
+	policy := NewPolicy([]Rule{{Principal: "bob", Path: "file", Allow: false}}, true)
+	s := newTestServer(t, newTestFs(nil), Options{Policy: policy})
+	bob := withPrincipal(context.Background(), &Principal{Name: "bob"})
+	resp, err := s.GetAttr(bob, &pb.GetAttrRequest{Name: "file"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status.Code != fuse.EACCES {
+		t.Fatalf("call without context: got %v, want EACCES", resp.Status.Code)
+	}
+	if _, err := s.StatFs(bob, &pb.StatFsRequest{Name: "file"}); grpc.Code(err) != codes.PermissionDenied {
+		t.Fatalf("StatFs: got %v, want PermissionDenied", err)
+	}
+	if _, err := s.StatFs(bob, &pb.StatFsRequest{Name: ""}); err != nil {
+		t.Fatalf("StatFs of an allowed path: %v", err)
+	}
+}
+
+func TestWatchPolicy(t *testing.T) {
+	policy := NewPolicy([]Rule{{Principal: "alice", Path: "pub/**", Ops: []OpClass{ReadOps}, Allow: true}}, false)
+	s := newTestServer(t, newTestFs(nil), Options{Policy: policy})
+	alice := withPrincipal(context.Background(), &Principal{Name: "alice"})
+	for _, c := range []struct {
+		event, want *pb.WatchEvent
+	}{
+		{&pb.WatchEvent{Op: pb.WatchOp_Rescan}, &pb.WatchEvent{Op: pb.WatchOp_Rescan}},
+		{&pb.WatchEvent{Op: pb.WatchOp_Modify, Name: "pub/a"}, &pb.WatchEvent{Op: pb.WatchOp_Modify, Name: "pub/a"}},
+		{&pb.WatchEvent{Op: pb.WatchOp_Create, Name: "secret/a"}, nil},
+		{&pb.WatchEvent{Op: pb.WatchOp_Rename, Name: "pub/a", NewName: "pub/b"}, &pb.WatchEvent{Op: pb.WatchOp_Rename, Name: "pub/a", NewName: "pub/b"}},
+		{&pb.WatchEvent{Op: pb.WatchOp_Rename, Name: "pub/a", NewName: "secret/a"}, &pb.WatchEvent{Op: pb.WatchOp_Delete, Name: "pub/a"}},
+		{&pb.WatchEvent{Op: pb.WatchOp_Rename, Name: "secret/a", NewName: "pub/a"}, &pb.WatchEvent{Op: pb.WatchOp_Create, Name: "pub/a"}},
+		{&pb.WatchEvent{Op: pb.WatchOp_Rename, Name: "secret/a", NewName: "secret/b"}, nil},
+	} {
+		got := s.visible(alice, c.event)
+		if got == nil && c.want == nil {
+			continue
+		}
+		if got == nil || c.want == nil || *got != *c.want {
+			t.Errorf("%v is seen as %v, want %v", c.event, got, c.want)
+		}
+	}
+}
+
+func TestChownUnmapped(t *testing.T) {
+	s := newTestServer(t, newTestFs(nil), Options{IDMap: &IDMap{
+		UIDs: []IDMapping{{Client: 1000, Server: 5000, Count: 10}},
+		GIDs: []IDMapping{{Client: 100, Server: 500, Count: 1}},
+	}})
+	for _, c := range []struct {
+		uid, gid uint32
+		want     fuse.Status
+	}{
+		// testFs doesn't implement Chown, so mapped ids get that far.
+		{1001, 100, fuse.ENOSYS},
+		{1001, unchangedID, fuse.ENOSYS},
+		{2000, 100, fuse.EINVAL},
+		{1001, 101, fuse.EINVAL},
+	} {
+		resp, err := s.Chown(context.Background(), &pb.ChownRequest{
+			Name:    "file",
+			UID:     c.uid,
+			GID:     c.gid,
+			Context: &pb.Context{Owner: &pb.Owner{Uid: 1000, Gid: 100}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Status.Code != c.want {
+			t.Errorf("chown to %d:%d: got %v, want %v", c.uid, c.gid, resp.Status.Code, c.want)
+		}
+	}
+}
+
+func TestChownSquashed(t *testing.T) {
+	for _, c := range []struct {
+		idMap    *IDMap
+		uid, gid uint32
+		want     fuse.Status
+	}{
+		{&IDMap{AllSquash: true}, 1000, 1000, fuse.EINVAL},
+		{&IDMap{AllSquash: true}, unchangedID, 1000, fuse.EINVAL},
+		{&IDMap{AllSquash: true}, unchangedID, unchangedID, fuse.ENOSYS},
+		{&IDMap{RootSquash: true}, 0, 1000, fuse.EINVAL},
+		{&IDMap{RootSquash: true}, 1000, 0, fuse.EINVAL},
+		{&IDMap{RootSquash: true}, 1000, 1000, fuse.ENOSYS},
+	} {
+		s := newTestServer(t, newTestFs(nil), Options{IDMap: c.idMap})
+		resp, err := s.Chown(context.Background(), &pb.ChownRequest{
+			Name:    "file",
+			UID:     c.uid,
+			GID:     c.gid,
+			Context: &pb.Context{Owner: &pb.Owner{Uid: 1000, Gid: 1000}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Status.Code != c.want {
+			t.Errorf("chown to %d:%d with %+v: got %v, want %v", c.uid, c.gid, *c.idMap, resp.Status.Code, c.want)
+		}
+	}
+}
+
+func TestMissingOwnerAnonymous(t *testing.T) {
+	for _, c := range []struct {
+		idMap *IDMap
+		want  fuse.Owner
+	}{
+		{nil, fuse.Owner{Uid: nobody, Gid: nobody}},
+		{&IDMap{AnonUID: 99, AnonGID: 98}, fuse.Owner{Uid: 99, Gid: 98}},
+	} {
+		for _, gctx := range []*pb.Context{nil, {Pid: 1}} {
+			fs := newTestFs(nil)
+			s := newTestServer(t, fs, Options{IDMap: c.idMap})
+			if _, err := s.GetAttr(context.Background(), &pb.GetAttrRequest{Name: "file", Context: gctx}); err != nil {
+				t.Fatal(err)
+			}
+			if fctx := fs.contexts[0]; fctx == nil || fctx.Owner != c.want {
+				t.Errorf("call with context %v acts as %+v, want %+v", gctx, fctx, c.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package server
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LK4D4/grfuse/pb"
+	"golang.org/x/net/context"
+)
+
+// watchBuffer is the number of events queued for a Watch stream. If a
+// client falls further behind, its queued events are replaced by a single
+// Rescan.
+const watchBuffer = 1024
+
+// expectDelay is how long a ChangeSource is expected to take to report a
+// change made through the server, after which the change is no longer
+// attributed to the client which made it.
+const expectDelay = time.Second
+
+// ChangeSource reports all changes made to the exported file system,
+// those made through the server as well as by anything else, e.g. local
+// processes. A server with a source takes changes from it only, so each
+// is reported once. Backends on local directories can use InotifySource,
+// others have to supply their own.
+type ChangeSource interface {
+	// Events returns the channel on which changes are delivered. Names
+	// are relative to the root of the exported file system.
+	Events() <-chan *pb.WatchEvent
+}
+
+// watchHub fans out change events to all Watch streams.
+type watchHub struct {
+	mu       sync.Mutex
+	watchers map[*watcher]struct{}
+	// expected maps the names changed through the server to the origins
+	// of the changes, until the source reports them. It's nil without a
+	// source.
+	expected map[string]expectation
+}
+
+type expectation struct {
+	origin string
+	until  time.Time
+}
+
+// change is an event along with the origin of the call which caused it, ""
+// if it wasn't made through the server.
+type change struct {
+	event  *pb.WatchEvent
+	origin string
+}
+
+type watcher struct {
+	events chan change
+	// lost is signalled when events were dropped because the watcher
+	// fell behind.
+	lost chan struct{}
+}
+
+// newWatchHub returns a hub publishing the changes reported by src, which
+// may be nil, and by the server.
+func newWatchHub(src ChangeSource) *watchHub {
+	h := &watchHub{
+		watchers: make(map[*watcher]struct{}),
+	}
+	if src != nil {
+		h.expected = make(map[string]expectation)
+		go h.forward(src)
+	}
+	return h
+}
+
+func (h *watchHub) subscribe() *watcher {
+	w := &watcher{
+		events: make(chan change, watchBuffer),
+		lost:   make(chan struct{}, 1),
+	}
+	h.mu.Lock()
+	h.watchers[w] = struct{}{}
+	h.mu.Unlock()
+	return w
+}
+
+func (h *watchHub) unsubscribe(w *watcher) {
+	h.mu.Lock()
+	delete(h.watchers, w)
+	h.mu.Unlock()
+}
+
+// publish sends e, caused by a call from origin, to every watcher without
+// blocking.
+func (h *watchHub) publish(e *pb.WatchEvent, origin string) {
+	h.mu.Lock()
+	for w := range h.watchers {
+		select {
+		case w.events <- change{event: e, origin: origin}:
+		default:
+			select {
+			case w.lost <- struct{}{}:
+			default:
+			}
+		}
+	}
+	h.mu.Unlock()
+}
+
+// made publishes e, made through the server by a call from origin. With a
+// source, which reports it as well, it's only expected.
+func (h *watchHub) made(e *pb.WatchEvent, origin string) {
+	if h.expected == nil {
+		h.publish(e, origin)
+		return
+	}
+	if e.NewName != "" {
+		h.expect(origin, e.Name, e.NewName)
+		return
+	}
+	h.expect(origin, e.Name)
+}
+
+// expect attributes the changes the source reports for names during the
+// next expectDelay to origin. It does nothing without a source.
+func (h *watchHub) expect(origin string, names ...string) {
+	if h.expected == nil {
+		return
+	}
+	now := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.expected) >= watchBuffer {
+		for name, x := range h.expected {
+			if now.After(x.until) {
+				delete(h.expected, name)
+			}
+		}
+	}
+	for _, name := range names {
+		if origin == "" {
+			delete(h.expected, name)
+			continue
+		}
+		h.expected[name] = expectation{origin: origin, until: now.Add(expectDelay)}
+	}
+}
+
+// origin returns the origin of a change reported by the source, "" if it
+// wasn't made through the server.
+func (h *watchHub) origin(e *pb.WatchEvent) string {
+	now := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, name := range []string{e.Name, e.NewName} {
+		if x, ok := h.expected[name]; ok && name != "" && now.Before(x.until) {
+			return x.origin
+		}
+	}
+	return ""
+}
+
+// forward publishes the events of src until its channel is closed.
+func (h *watchHub) forward(src ChangeSource) {
+	for e := range src.Events() {
+		h.publish(e, h.origin(e))
+	}
+}
+
+// below reports whether name is dir or inside of it.
+func below(name, dir string) bool {
+	return dir == "" || name == dir || strings.HasPrefix(name, dir+"/")
+}
+
+// visible returns e as the principal of ctx may see it under the policy
+// of the server, or nil if it may see nothing of it. A file renamed from
+// or to a name it can't read just appears or disappears.
+func (s *fuseServer) visible(ctx context.Context, e *pb.WatchEvent) *pb.WatchEvent {
+	if s.policy == nil || e.Op == pb.WatchOp_Rescan {
+		return e
+	}
+	principal := principalName(ctx)
+	oldOK := s.policy.allows(principal, e.Name, ReadOps, false, nil)
+	if e.Op != pb.WatchOp_Rename {
+		if !oldOK {
+			return nil
+		}
+		return e
+	}
+	newOK := s.policy.allows(principal, e.NewName, ReadOps, false, nil)
+	switch {
+	case oldOK && newOK:
+		return e
+	case oldOK:
+		return &pb.WatchEvent{Op: pb.WatchOp_Delete, Name: e.Name}
+	case newOK:
+		return &pb.WatchEvent{Op: pb.WatchOp_Create, Name: e.NewName}
+	}
+	return nil
+}
+
+func (s *fuseServer) Watch(r *pb.WatchRequest, stream pb.PathFS_WatchServer) error {
+	ctx, done := s.startCall(stream.Context(), "Watch", r.Name)
+	defer done()
+	// The client already knows about the changes it made itself.
+	self := origin(ctx)
+	w := s.watches.subscribe()
+	defer s.watches.unsubscribe(w)
+	if err := stream.Send(&pb.WatchEvent{Op: pb.WatchOp_Rescan}); err != nil {
+		return err
+	}
+	for {
+		select {
+		case c := <-w.events:
+			if self != "" && c.origin == self {
+				continue
+			}
+			e := c.event
+			if e.Op != pb.WatchOp_Rescan && !below(e.Name, r.Name) && !below(e.NewName, r.Name) {
+				continue
+			}
+			if e = s.visible(ctx, e); e == nil {
+				continue
+			}
+			if err := stream.Send(e); err != nil {
+				return err
+			}
+		case <-w.lost:
+			// Whatever is still queued is covered by the Rescan.
+			for len(w.events) > 0 {
+				<-w.events
+			}
+			if err := stream.Send(&pb.WatchEvent{Op: pb.WatchOp_Rescan}); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,291 @@
+// Package tlsutil builds gRPC options for TLS and mutual TLS between grfuse
+// servers and clients from PEM files:
+//
+//	opt, err := tlsutil.ServerOption(tlsutil.Config{
+//		CertFile: "server.pem",
+//		KeyFile:  "server-key.pem",
+//		CAFile:   "clients-ca.pem",
+//	})
+//	s := grpc.NewServer(opt)
+//
+// Certificates are loaded again when the files change, so they can be
+// rotated without restarting the server or remounting the client.
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// DefaultReloadInterval is how often the files are checked for changes
+// if Config.ReloadInterval is zero.
+const DefaultReloadInterval = 10 * time.Second
+
+// Config names the PEM files used for TLS.
+type Config struct {
+	// CertFile and KeyFile hold the certificate chain and key of this
+	// side. Servers need them; clients only to authenticate themselves
+	// to servers which require client certificates.
+	CertFile string
+	KeyFile  string
+	// CAFile holds the certificates of the authorities the other side
+	// is verified against. Servers with a CAFile require clients to
+	// present a certificate signed by one of them. Clients without one
+	// use the system's roots.
+	CAFile string
+	// ServerName is the name the server's certificate is verified
+	// against. If empty, the host part of the dialed address is used,
+	// which may be an IP address.
+	ServerName string
+	// ReloadInterval is how often, at most, the files are checked for
+	// changes during handshakes. Negative disables reloading.
+	ReloadInterval time.Duration
+}
+
+// ServerOption returns the option making a gRPC server use TLS.
+func ServerOption(cfg Config) (grpc.ServerOption, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("tlsutil: server needs CertFile and KeyFile")
+	}
+	f, err := newFiles(cfg)
+	if err != nil {
+		return nil, err
+	}
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	tc := base.Clone()
+	tc.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cert, pool := f.get()
+		c := base.Clone()
+		c.Certificates = []tls.Certificate{*cert}
+		if pool != nil {
+			c.ClientCAs = pool
+			c.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		return c, nil
+	}
+	return grpc.Creds(credentials.NewTLS(tc)), nil
+}
+
+// DialOption returns the option making a gRPC client use TLS.
+func DialOption(cfg Config) (grpc.DialOption, error) {
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, errors.New("tlsutil: CertFile and KeyFile must be given together")
+	}
+	f, err := newFiles(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tc := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.ServerName,
+	}
+	if cfg.CertFile != "" {
+		tc.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := f.get()
+			return cert, nil
+		}
+	}
+	if cfg.CAFile != "" {
+		// The roots can change, so the server is verified here
+		// instead of by crypto/tls.
+		tc.InsecureSkipVerify = true
+		return grpc.WithTransportCredentials(&clientCreds{
+			TransportCredentials: credentials.NewTLS(tc),
+			tc:                   tc,
+			files:                f,
+		}), nil
+	}
+	return grpc.WithTransportCredentials(credentials.NewTLS(tc)), nil
+}
+
+// clientCreds verifies servers against the CA pool of files. The name
+// the server is verified against comes from the dialed address, as the
+// connection state has none for IP addresses.
+type clientCreds struct {
+	credentials.TransportCredentials
+	tc    *tls.Config
+	files *files
+}
+
+func (c *clientCreds) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	host := c.tc.ServerName
+	if host == "" {
+		host = authority
+		if h, _, err := net.SplitHostPort(authority); err == nil {
+			host = h
+		}
+	}
+	if host == "" {
+		return nil, nil, errors.New("tlsutil: no server name to verify the server against")
+	}
+	tc := c.tc.Clone()
+	tc.VerifyConnection = func(cs tls.ConnectionState) error {
+		_, pool := c.files.get()
+		return verifyServer(cs, host, pool)
+	}
+	return credentials.NewTLS(tc).ClientHandshake(ctx, authority, conn)
+}
+
+func (c *clientCreds) Clone() credentials.TransportCredentials {
+	return &clientCreds{
+		TransportCredentials: c.TransportCredentials.Clone(),
+		tc:                   c.tc.Clone(),
+		files:                c.files,
+	}
+}
+
+func (c *clientCreds) OverrideServerName(name string) error {
+	c.tc.ServerName = name
+	return c.TransportCredentials.OverrideServerName(name)
+}
+
+func verifyServer(cs tls.ConnectionState, host string, roots *x509.CertPool) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("tlsutil: server sent no certificate")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		DNSName:       host,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, c := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// PeerCertificate returns the verified certificate the peer of a call
+// presented, which identifies the client to a server using mutual TLS.
+func PeerCertificate(ctx context.Context) (*x509.Certificate, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	return info.State.VerifiedChains[0][0], true
+}
+
+// files keeps the certificate and CA pool loaded from the files of a
+// Config, reloading them once they change.
+type files struct {
+	cfg      Config
+	interval time.Duration
+
+	mu      sync.Mutex
+	checked time.Time
+	mtimes  map[string]time.Time
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+}
+
+func newFiles(cfg Config) (*files, error) {
+	f := &files{
+		cfg:      cfg,
+		interval: cfg.ReloadInterval,
+	}
+	if f.interval == 0 {
+		f.interval = DefaultReloadInterval
+	}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	f.checked = time.Now()
+	return f, nil
+}
+
+func (f *files) names() []string {
+	var names []string
+	for _, n := range []string{f.cfg.CertFile, f.cfg.KeyFile, f.cfg.CAFile} {
+		if n != "" {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// load reads all files. Nothing changes if one can't be read.
+func (f *files) load() error {
+	mtimes := make(map[string]time.Time)
+	for _, n := range f.names() {
+		fi, err := os.Stat(n)
+		if err != nil {
+			return err
+		}
+		mtimes[n] = fi.ModTime()
+	}
+	var cert *tls.Certificate
+	if f.cfg.CertFile != "" {
+		c, err := tls.LoadX509KeyPair(f.cfg.CertFile, f.cfg.KeyFile)
+		if err != nil {
+			return err
+		}
+		cert = &c
+	}
+	var pool *x509.CertPool
+	if f.cfg.CAFile != "" {
+		pem, err := ioutil.ReadFile(f.cfg.CAFile)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("tlsutil: no certificates in %s", f.cfg.CAFile)
+		}
+	}
+	f.mtimes = mtimes
+	f.cert = cert
+	f.pool = pool
+	return nil
+}
+
+// changed reports whether any file was modified since it was loaded.
+func (f *files) changed() bool {
+	for n, mtime := range f.mtimes {
+		fi, err := os.Stat(n)
+		if err != nil {
+			// Most likely in the middle of being replaced.
+			return false
+		}
+		if !fi.ModTime().Equal(mtime) {
+			return true
+		}
+	}
+	return false
+}
+
+// get returns the current certificate and CA pool. If the files changed,
+// they are loaded again; if that fails, the old ones stay in use.
+func (f *files) get() (*tls.Certificate, *x509.CertPool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.interval > 0 && time.Since(f.checked) >= f.interval {
+		f.checked = time.Now()
+		if f.changed() {
+			if err := f.load(); err != nil {
+				log.Printf("Error reloading TLS certificates, keeping the old ones: %v", err)
+			} else {
+				log.Printf("Reloaded TLS certificates from %v", f.names())
+			}
+		}
+	}
+	return f.cert, f.pool
+}
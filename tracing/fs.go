@@ -0,0 +1,194 @@
+package tracing
+
+import (
+	"time"
+
+	"github.com/LK4D4/grfuse/server"
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/nodefs"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+)
+
+// FileSystem returns fs with a span for each of its calls, in the trace of
+// the server call it's made for. Calls on open files aren't traced apart
+// from the server calls making them.
+func FileSystem(fs pathfs.FileSystem) pathfs.FileSystem {
+	return &fileSystem{FileSystem: fs}
+}
+
+type fileSystem struct {
+	pathfs.FileSystem
+}
+
+// start starts the span of the call op on name, which the file system
+// got fctx for.
+func (fs *fileSystem) start(op, name string, fctx *fuse.Context) trace.Span {
+	ctx, ok := server.CallContext(fctx)
+	if !ok {
+		ctx = context.Background()
+	}
+	_, span := tracer().Start(ctx, "FileSystem."+op,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String("grfuse.op", op),
+			attribute.String("grfuse.path", name),
+		),
+	)
+	return span
+}
+
+// end ends span with the status its call returned.
+func end(span trace.Span, code fuse.Status) {
+	setStatus(span, code)
+	span.End()
+}
+
+func (fs *fileSystem) GetAttr(name string, context *fuse.Context) (*fuse.Attr, fuse.Status) {
+	span := fs.start("GetAttr", name, context)
+	attr, code := fs.FileSystem.GetAttr(name, context)
+	end(span, code)
+	return attr, code
+}
+
+func (fs *fileSystem) Chmod(name string, mode uint32, context *fuse.Context) fuse.Status {
+	span := fs.start("Chmod", name, context)
+	code := fs.FileSystem.Chmod(name, mode, context)
+	end(span, code)
+	return code
+}
+
+func (fs *fileSystem) Chown(name string, uid uint32, gid uint32, context *fuse.Context) fuse.Status {
+	span := fs.start("Chown", name, context)
+	code := fs.FileSystem.Chown(name, uid, gid, context)
+	end(span, code)
+	return code
+}
+
+func (fs *fileSystem) Utimens(name string, atime *time.Time, mtime *time.Time, context *fuse.Context) fuse.Status {
+	span := fs.start("Utimens", name, context)
+	code := fs.FileSystem.Utimens(name, atime, mtime, context)
+	end(span, code)
+	return code
+}
+
+func (fs *fileSystem) Truncate(name string, size uint64, context *fuse.Context) fuse.Status {
+	span := fs.start("Truncate", name, context)
+	code := fs.FileSystem.Truncate(name, size, context)
+	end(span, code)
+	return code
+}
+
+func (fs *fileSystem) Access(name string, mode uint32, context *fuse.Context) fuse.Status {
+	span := fs.start("Access", name, context)
+	code := fs.FileSystem.Access(name, mode, context)
+	end(span, code)
+	return code
+}
+
+func (fs *fileSystem) Link(oldName string, newName string, context *fuse.Context) fuse.Status {
+	span := fs.start("Link", oldName, context)
+	code := fs.FileSystem.Link(oldName, newName, context)
+	end(span, code)
+	return code
+}
+
+func (fs *fileSystem) Mkdir(name string, mode uint32, context *fuse.Context) fuse.Status {
+	span := fs.start("Mkdir", name, context)
+	code := fs.FileSystem.Mkdir(name, mode, context)
+	end(span, code)
+	return code
+}
+
+func (fs *fileSystem) Mknod(name string, mode uint32, dev uint32, context *fuse.Context) fuse.Status {
+	span := fs.start("Mknod", name, context)
+	code := fs.FileSystem.Mknod(name, mode, dev, context)
+	end(span, code)
+	return code
+}
+
+func (fs *fileSystem) Rename(oldName string, newName string, context *fuse.Context) fuse.Status {
+	span := fs.start("Rename", oldName, context)
+	code := fs.FileSystem.Rename(oldName, newName, context)
+	end(span, code)
+	return code
+}
+
+func (fs *fileSystem) Rmdir(name string, context *fuse.Context) fuse.Status {
+	span := fs.start("Rmdir", name, context)
+	code := fs.FileSystem.Rmdir(name, context)
+	end(span, code)
+	return code
+}
+
+func (fs *fileSystem) Unlink(name string, context *fuse.Context) fuse.Status {
+	span := fs.start("Unlink", name, context)
+	code := fs.FileSystem.Unlink(name, context)
+	end(span, code)
+	return code
+}
+
+func (fs *fileSystem) GetXAttr(name string, attr string, context *fuse.Context) ([]byte, fuse.Status) {
+	span := fs.start("GetXAttr", name, context)
+	data, code := fs.FileSystem.GetXAttr(name, attr, context)
+	end(span, code)
+	return data, code
+}
+
+func (fs *fileSystem) ListXAttr(name string, context *fuse.Context) ([]string, fuse.Status) {
+	span := fs.start("ListXAttr", name, context)
+	attrs, code := fs.FileSystem.ListXAttr(name, context)
+	end(span, code)
+	return attrs, code
+}
+
+func (fs *fileSystem) RemoveXAttr(name string, attr string, context *fuse.Context) fuse.Status {
+	span := fs.start("RemoveXAttr", name, context)
+	code := fs.FileSystem.RemoveXAttr(name, attr, context)
+	end(span, code)
+	return code
+}
+
+func (fs *fileSystem) SetXAttr(name string, attr string, data []byte, flags int, context *fuse.Context) fuse.Status {
+	span := fs.start("SetXAttr", name, context)
+	code := fs.FileSystem.SetXAttr(name, attr, data, flags, context)
+	end(span, code)
+	return code
+}
+
+func (fs *fileSystem) Open(name string, flags uint32, context *fuse.Context) (nodefs.File, fuse.Status) {
+	span := fs.start("Open", name, context)
+	file, code := fs.FileSystem.Open(name, flags, context)
+	end(span, code)
+	return file, code
+}
+
+func (fs *fileSystem) Create(name string, flags uint32, mode uint32, context *fuse.Context) (nodefs.File, fuse.Status) {
+	span := fs.start("Create", name, context)
+	file, code := fs.FileSystem.Create(name, flags, mode, context)
+	end(span, code)
+	return file, code
+}
+
+func (fs *fileSystem) OpenDir(name string, context *fuse.Context) ([]fuse.DirEntry, fuse.Status) {
+	span := fs.start("OpenDir", name, context)
+	c, code := fs.FileSystem.OpenDir(name, context)
+	end(span, code)
+	return c, code
+}
+
+func (fs *fileSystem) Symlink(value string, linkName string, context *fuse.Context) fuse.Status {
+	span := fs.start("Symlink", linkName, context)
+	code := fs.FileSystem.Symlink(value, linkName, context)
+	end(span, code)
+	return code
+}
+
+func (fs *fileSystem) Readlink(name string, context *fuse.Context) (string, fuse.Status) {
+	span := fs.start("Readlink", name, context)
+	value, code := fs.FileSystem.Readlink(name, context)
+	end(span, code)
+	return value, code
+}
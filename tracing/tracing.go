@@ -0,0 +1,290 @@
+// Package tracing traces grfuse calls with OpenTelemetry, from the client
+// through the server to the file system it exports. Calls are traced by
+// gRPC interceptors, so neither the server nor the client package depends
+// on OpenTelemetry. For a server:
+//
+//	s := grpc.NewServer(
+//		grpc.UnaryInterceptor(tracing.UnaryServerInterceptor()),
+//		grpc.StreamInterceptor(tracing.StreamServerInterceptor()),
+//	)
+//	pb.RegisterPathFSServer(s, server.New(tracing.FileSystem(fs)))
+//
+// Clients pass the client interceptors to grpc.Dial instead. Spans are
+// only recorded once a tracer provider is set with otel.SetTracerProvider,
+// and the trace context is passed with the propagator set with
+// otel.SetTextMapPropagator.
+package tracing
+
+import (
+	"io"
+	"path"
+	"sync"
+
+	"github.com/LK4D4/grfuse/pb"
+	"github.com/hanwen/go-fuse/fuse"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// tracerName identifies the spans of grfuse.
+const tracerName = "github.com/LK4D4/grfuse/tracing"
+
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// metadataCarrier passes trace context in gRPC metadata.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c)[key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c)[key] = []string{value}
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// methodName returns the name of the method called, e.g. "Read" for
+// "/pb.PathFS/Read".
+func methodName(fullMethod string) string {
+	return path.Base(fullMethod)
+}
+
+// requestPath returns the path a request is about, or "" for requests on
+// file handles.
+func requestPath(req interface{}) string {
+	switch r := req.(type) {
+	case interface {
+		GetName() string
+	}:
+		return r.GetName()
+	case interface {
+		GetOldName() string
+	}:
+		return r.GetOldName()
+	case interface {
+		GetLinkName() string
+	}:
+		return r.GetLinkName()
+	}
+	return ""
+}
+
+// setPath records the path of req in span, if it has one.
+func setPath(span trace.Span, req interface{}) {
+	if name := requestPath(req); name != "" {
+		span.SetAttributes(attribute.String("grfuse.path", name))
+	}
+}
+
+// setResponse records the status of a response in span, if it has one.
+func setResponse(span trace.Span, resp interface{}) {
+	r, ok := resp.(interface {
+		GetStatus() *pb.Status
+	})
+	if !ok || r.GetStatus() == nil {
+		return
+	}
+	setStatus(span, r.GetStatus().Code)
+}
+
+// setStatus records the status a call ended with in span.
+func setStatus(span trace.Span, code fuse.Status) {
+	span.SetAttributes(attribute.Int("grfuse.status", int(code)))
+	if code != fuse.OK {
+		span.SetStatus(otelcodes.Error, code.String())
+	}
+}
+
+// setError records the error a call failed with in span.
+func setError(span trace.Span, err error) {
+	if err == nil || err == io.EOF {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(otelcodes.Error, err.Error())
+}
+
+// startClient starts the span of a call to method and attaches its trace
+// context to the metadata sent with it, keeping the metadata set already.
+func startClient(ctx context.Context, method string) (context.Context, trace.Span) {
+	ctx, span := tracer().Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("grfuse.op", method)),
+	)
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md), span
+}
+
+// startServer starts the span of a call to method, continuing the trace
+// of the client.
+func startServer(ctx context.Context, method string) (context.Context, trace.Span) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	}
+	return tracer().Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("grfuse.op", method)),
+	)
+}
+
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, span := startServer(ctx, methodName(info.FullMethod))
+		defer span.End()
+		setPath(span, req)
+		resp, err := handler(ctx, req)
+		if err != nil {
+			setError(span, err)
+		} else {
+			setResponse(span, resp)
+		}
+		return resp, err
+	}
+}
+
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := startServer(ss.Context(), methodName(info.FullMethod))
+		defer span.End()
+		err := handler(srv, &serverStream{
+			ServerStream: ss,
+			ctx:          ctx,
+			span:         span,
+		})
+		setError(span, err)
+		return err
+	}
+}
+
+// serverStream carries the span of a stream in its context, and records
+// the path of its first request and the statuses of its responses.
+type serverStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	span     trace.Span
+	received bool
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) SendMsg(msg interface{}) error {
+	err := s.ServerStream.SendMsg(msg)
+	if err == nil {
+		setResponse(s.span, msg)
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(msg interface{}) error {
+	err := s.ServerStream.RecvMsg(msg)
+	if err == nil && !s.received {
+		s.received = true
+		setPath(s.span, msg)
+	}
+	return err
+}
+
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, fullMethod string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := startClient(ctx, methodName(fullMethod))
+		defer span.End()
+		setPath(span, req)
+		err := invoker(ctx, fullMethod, req, reply, cc, opts...)
+		if err != nil {
+			setError(span, err)
+		} else {
+			setResponse(span, reply)
+		}
+		return err
+	}
+}
+
+// StreamClientInterceptor ends the span of a stream once the stream ends,
+// or once its context is canceled.
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, fullMethod string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, span := startClient(ctx, methodName(fullMethod))
+		cs, err := streamer(ctx, desc, cc, fullMethod, opts...)
+		if err != nil {
+			setError(span, err)
+			span.End()
+			return nil, err
+		}
+		s := &clientStream{
+			ClientStream:  cs,
+			span:          span,
+			serverStreams: desc.ServerStreams,
+		}
+		if done := ctx.Done(); done != nil {
+			go func() {
+				<-done
+				s.finish(ctx.Err())
+			}()
+		}
+		return s, nil
+	}
+}
+
+type clientStream struct {
+	grpc.ClientStream
+	span          trace.Span
+	serverStreams bool
+	sent          bool
+	once          sync.Once
+}
+
+func (s *clientStream) SendMsg(msg interface{}) error {
+	err := s.ClientStream.SendMsg(msg)
+	if err == nil && !s.sent {
+		s.sent = true
+		setPath(s.span, msg)
+	}
+	return err
+}
+
+// RecvMsg ends the span at the end of the stream, or after the single
+// response of a call which only streams requests.
+func (s *clientStream) RecvMsg(msg interface{}) error {
+	err := s.ClientStream.RecvMsg(msg)
+	if err != nil {
+		s.finish(err)
+		return err
+	}
+	setResponse(s.span, msg)
+	if !s.serverStreams {
+		s.finish(nil)
+	}
+	return nil
+}
+
+func (s *clientStream) finish(err error) {
+	s.once.Do(func() {
+		setError(s.span, err)
+		s.span.End()
+	})
+}
@@ -0,0 +1,120 @@
+package tracing
+
+import (
+	"net"
+	"testing"
+
+	"github.com/LK4D4/grfuse/grpcfs"
+	"github.com/LK4D4/grfuse/pb"
+	"github.com/LK4D4/grfuse/server"
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// testFs has a file and a directory listing it. It records the ids
+// clients sent along with the calls it gets.
+type testFs struct {
+	pathfs.FileSystem
+	clientIDs []string
+}
+
+func (fs *testFs) GetAttr(name string, context *fuse.Context) (*fuse.Attr, fuse.Status) {
+	if ctx, ok := server.CallContext(context); ok {
+		md, _ := metadata.FromIncomingContext(ctx)
+		fs.clientIDs = append(fs.clientIDs, md["grfuse-client-id"]...)
+	}
+	switch name {
+	case "":
+		return &fuse.Attr{Mode: fuse.S_IFDIR | 0755}, fuse.OK
+	case "file":
+		return &fuse.Attr{Mode: fuse.S_IFREG | 0644}, fuse.OK
+	}
+	return nil, fuse.ENOENT
+}
+
+func (fs *testFs) OpenDir(name string, context *fuse.Context) ([]fuse.DirEntry, fuse.Status) {
+	return []fuse.DirEntry{{Name: "file", Mode: fuse.S_IFREG}}, fuse.OK
+}
+
+// findSpan returns the span named name of kind.
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string, kind trace.SpanKind) tracetest.SpanStub {
+	for _, s := range spans {
+		if s.Name == name && s.SpanKind == kind {
+			return s
+		}
+	}
+	t.Fatalf("no %v span %s in %v", kind, name, spans)
+	return tracetest.SpanStub{}
+}
+
+func TestTrace(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := &testFs{FileSystem: pathfs.NewDefaultFileSystem()}
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor()),
+		grpc.StreamInterceptor(StreamServerInterceptor()),
+	)
+	pb.RegisterPathFSServer(s, server.New(FileSystem(backend)))
+	go s.Serve(l)
+	defer s.Stop()
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(StreamClientInterceptor()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fs := grpcfs.New(pb.NewPathFSClient(conn))
+
+	if _, code := fs.GetAttr("file", &fuse.Context{}); code != fuse.OK {
+		t.Fatalf("GetAttr: %v", code)
+	}
+	if _, code := fs.OpenDir("", &fuse.Context{}); code != fuse.OK {
+		t.Fatalf("OpenDir: %v", code)
+	}
+	if len(backend.clientIDs) == 0 || backend.clientIDs[0] == "" {
+		t.Errorf("the client id wasn't sent along with the trace context: %v", backend.clientIDs)
+	}
+
+	spans := exporter.GetSpans()
+	for _, c := range []struct {
+		method, op string
+	}{
+		{"GetAttr", "GetAttr"},
+		{"OpenDirStream", "OpenDir"},
+	} {
+		client := findSpan(t, spans, c.method, trace.SpanKindClient)
+		srv := findSpan(t, spans, c.method, trace.SpanKindServer)
+		fsSpan := findSpan(t, spans, "FileSystem."+c.op, trace.SpanKindInternal)
+		if client.Parent.IsValid() {
+			t.Errorf("%s: client span has a parent %v", c.method, client.Parent.SpanID())
+		}
+		if srv.Parent.SpanID() != client.SpanContext.SpanID() {
+			t.Errorf("%s: server span's parent is %v, want the client span %v", c.method, srv.Parent.SpanID(), client.SpanContext.SpanID())
+		}
+		if fsSpan.Parent.SpanID() != srv.SpanContext.SpanID() {
+			t.Errorf("%s: file system span's parent is %v, want the server span %v", c.method, fsSpan.Parent.SpanID(), srv.SpanContext.SpanID())
+		}
+		for _, s := range []tracetest.SpanStub{srv, fsSpan} {
+			if s.SpanContext.TraceID() != client.SpanContext.TraceID() {
+				t.Errorf("%s: span %s is in trace %v, want %v", c.method, s.Name, s.SpanContext.TraceID(), client.SpanContext.TraceID())
+			}
+		}
+	}
+}